@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/google/uuid"
+)
+
+// authMethod says how the caller of a request proved who they are.
+type authMethod string
+
+const (
+	// authMethodSession is an access token from a first-party login.
+	authMethodSession authMethod = "session"
+	// authMethodPersonalAccessToken is a token a user minted for a script.
+	authMethodPersonalAccessToken authMethod = "personal_access_token"
+	// authMethodOAuth is an access token issued to a third-party app.
+	authMethodOAuth authMethod = "oauth"
+)
+
+// principal is the authenticated caller of a request.
+type principal struct {
+	UserID        uuid.UUID
+	Method        authMethod
+	Scopes        []string  // granted to a personal access token or OAuth app
+	ClientID      uuid.UUID // the OAuth app, if Method is authMethodOAuth
+	Role          string
+	IsPremium     bool
+	EmailVerified bool
+}
+
+// can reports whether the principal may do what scope covers. Sessions can do
+// anything, delegated credentials only what they were granted; an empty scope
+// marks things reserved for sessions.
+func (p *principal) can(scope string) bool {
+	if p.Method == authMethodSession {
+		return true
+	}
+	return scope != "" && slices.Contains(p.Scopes, scope)
+}
+
+// contextKey keys the values middleware stores in a request context.
+type contextKey int
+
+const principalKey contextKey = iota
+
+// principalFrom returns the caller stored by the auth middleware, or nil for
+// anonymous requests.
+func principalFrom(r *http.Request) *principal {
+	p, _ := r.Context().Value(principalKey).(*principal)
+	return p
+}
+
+// authPolicy declares who may call a route.
+type authPolicy struct {
+	// Optional lets requests without credentials through anonymously.
+	// Credentials that are sent must still be valid.
+	Optional bool
+	// Scope is what a delegated credential needs; empty means sessions only.
+	Scope string
+	// Role is the minimum role required. Roles are only honoured for sessions.
+	Role string
+	// Write marks routes that make changes, which accounts without a
+	// verified email can't use while unverified accounts are read-only.
+	Write bool
+}
+
+// requireAuth rejects requests without a principal allowed scope.
+func (cfg *apiConfig) requireAuth(scope string, next http.HandlerFunc) http.Handler {
+	return cfg.withAuth(authPolicy{Scope: scope}, next)
+}
+
+// requireWriteAuth is requireAuth for routes that make changes, so they are
+// also closed to read-only accounts.
+func (cfg *apiConfig) requireWriteAuth(scope string, next http.HandlerFunc) http.Handler {
+	return cfg.withAuth(authPolicy{Scope: scope, Write: true}, next)
+}
+
+// optionalAuth serves anonymous callers too, so that next can tailor its
+// response to the principal when there is one.
+func (cfg *apiConfig) optionalAuth(scope string, next http.HandlerFunc) http.Handler {
+	return cfg.withAuth(authPolicy{Optional: true, Scope: scope}, next)
+}
+
+// requireRole rejects requests from anyone but sessions of users with at
+// least role. Personal access tokens and OAuth apps never get elevated
+// privileges.
+func (cfg *apiConfig) requireRole(role string, next http.HandlerFunc) http.Handler {
+	return cfg.withAuth(authPolicy{Role: role}, next)
+}
+
+// withAuth resolves the caller of every request once, enforces policy and
+// stores the principal in the request context for next. Missing or invalid
+// credentials get a 401, credentials that don't cover the route a 403.
+func (cfg *apiConfig) withAuth(policy authPolicy, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if policy.Optional && r.Header.Get("Authorization") == "" {
+			next(w, r)
+			return
+		}
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondUnauthenticated(w)
+			return
+		}
+
+		var p *principal
+		if auth.IsPersonalAccessToken(token) {
+			if policy.Role != "" {
+				handleErrorForbidden(w)
+				return
+			}
+			p, err = cfg.personalAccessTokenPrincipal(token)
+		} else {
+			p, err = cfg.accessTokenPrincipal(token)
+		}
+		if errors.Is(err, auth.ErrInvalidJWT) {
+			respondUnauthenticated(w)
+			return
+		}
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		if !p.can(policy.Scope) && policy.Role == "" ||
+			policy.Role != "" && (p.Method != authMethodSession || !auth.HasRole(p.Role, policy.Role)) {
+			handleErrorForbidden(w)
+			return
+		}
+
+		user, err := cfg.dbq.GetPrincipalUser(context.Background(), database.GetPrincipalUserParams{Now: time.Now().UTC(), ID: p.UserID})
+		if errors.Is(err, sql.ErrNoRows) {
+			// The account was deleted after the token was issued.
+			respondUnauthenticated(w)
+			return
+		}
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		if policy.Role != "" && !auth.HasRole(user.Role, policy.Role) {
+			// The role was taken away after the token was issued.
+			handleErrorForbidden(w)
+			return
+		}
+		p.IsPremium = user.IsPremium
+		p.EmailVerified = user.EmailVerifiedAt.Valid
+		if policy.Write && !cfg.requireVerifiedEmail(w, p) {
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey, p)))
+	})
+}
+
+// respondUnauthenticated is the 401 for requests without usable credentials.
+func respondUnauthenticated(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	handleErrorUnauthorized(w)
+}
+
+// accessTokenPrincipal resolves a JWT from a login or an OAuth app.
+func (cfg *apiConfig) accessTokenPrincipal(token string) (*principal, error) {
+	accessToken, err := cfg.jwt_keys.ParseAccessToken(token)
+	if err != nil {
+		return nil, err
+	}
+	p := &principal{UserID: accessToken.UserID, Method: authMethodSession, Role: accessToken.Role}
+	if accessToken.ClientID != uuid.Nil {
+		p.Method = authMethodOAuth
+		p.ClientID = accessToken.ClientID
+		p.Scopes = accessToken.Scopes
+	}
+	return p, nil
+}
+
+// personalAccessTokenPrincipal looks up a personal access token. Unknown,
+// revoked and expired tokens are reported as auth.ErrInvalidJWT, like any
+// other bad bearer token.
+func (cfg *apiConfig) personalAccessTokenPrincipal(token string) (*principal, error) {
+	pat, err := cfg.dbq.GetPersonalAccessTokenByHash(context.Background(), auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, auth.ErrInvalidJWT
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if pat.RevokedAt.Valid || pat.ExpiresAt.Before(now) {
+		return nil, auth.ErrInvalidJWT
+	}
+	// Bots can be chatty, a minute of precision is plenty.
+	if !pat.LastUsedAt.Valid || now.Sub(pat.LastUsedAt.Time) > time.Minute {
+		err = cfg.dbq.TouchPersonalAccessToken(context.Background(), database.TouchPersonalAccessTokenParams{LastUsedAt: sql.NullTime{Time: now, Valid: true}, ID: pat.ID})
+		if err != nil {
+			log.Printf("Error updating last use of token %s: %s", pat.ID, err)
+		}
+	}
+	return &principal{UserID: pat.UserID, Method: authMethodPersonalAccessToken, Scopes: pat.Scopes, Role: auth.RoleUser}, nil
+}
+
+// requireVerifiedEmail reports whether p may make changes. When unverified
+// accounts are read-only and the user hasn't verified their email yet, it
+// writes a 403 and returns false.
+func (cfg *apiConfig) requireVerifiedEmail(w http.ResponseWriter, p *principal) bool {
+	if !cfg.unverified_read_only || p.EmailVerified {
+		return true
+	}
+	respondWithError(w, 403, "verify your email address first")
+	return false
+}
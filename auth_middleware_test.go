@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAuthRejects(t *testing.T) {
+	// None of these get as far as the database.
+	cfg := &apiConfig{jwt_keys: auth.NewHMACKeySet("test-secret")}
+	userID := uuid.New()
+	session := func(role string) string {
+		token, err := cfg.jwt_keys.MakeJWTWithRole(userID, role, time.Hour)
+		require.NoError(t, err)
+		return token
+	}
+	oauthToken, err := cfg.jwt_keys.MakeOAuthJWT(userID, uuid.New(), []string{auth.ScopeZingersRead}, time.Hour)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		policy authPolicy
+		token  string
+		status int
+	}{
+		"No token":                  {authPolicy{Scope: auth.ScopeZingersWrite}, "", 401},
+		"Bad token":                 {authPolicy{Scope: auth.ScopeZingersWrite}, "not-a-jwt", 401},
+		"Bad token on optional":     {authPolicy{Optional: true, Scope: auth.ScopeZingersRead}, "not-a-jwt", 401},
+		"Missing scope":             {authPolicy{Scope: auth.ScopeZingersWrite}, oauthToken, 403},
+		"Missing scope on optional": {authPolicy{Optional: true, Scope: auth.ScopeProfileWrite}, oauthToken, 403},
+		"Sessions only":             {authPolicy{}, oauthToken, 403},
+		"Role for a user":           {authPolicy{Role: auth.RoleModerator}, session(auth.RoleUser), 403},
+		"Role for an unknown role":  {authPolicy{Role: auth.RoleModerator}, session("superuser"), 403},
+		"Role for an OAuth app":     {authPolicy{Role: auth.RoleUser}, oauthToken, 403},
+		"Role for a personal token": {authPolicy{Role: auth.RoleUser}, "zzp_abc", 403},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := cfg.withAuth(tc.policy, func(w http.ResponseWriter, r *http.Request) {
+				t.Error("handler should not be called")
+			})
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.status, rec.Code)
+			if tc.status == 401 {
+				assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	t.Run("Anonymous on optional", func(t *testing.T) {
+		called := false
+		handler := cfg.optionalAuth(auth.ScopeZingersRead, func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			assert.Nil(t, principalFrom(r))
+		})
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		assert.True(t, called)
+	})
+}
+
+func TestWithAuthPrincipal(t *testing.T) {
+	cfg, _ := newTestAPI(t)
+	user, _ := createTestUser(t, cfg)
+	err := cfg.dbq.SetUserRole(context.Background(), database.SetUserRoleParams{Role: auth.RoleModerator, UpdatedAt: time.Now().UTC(), ID: user.ID})
+	require.NoError(t, err)
+	token, err := cfg.jwt_keys.MakeJWTWithRole(user.ID, auth.RoleModerator, time.Hour)
+	require.NoError(t, err)
+
+	var got *principal
+	handler := cfg.requireRole(auth.RoleModerator, func(w http.ResponseWriter, r *http.Request) {
+		got = principalFrom(r)
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NotNil(t, got)
+	assert.Equal(t, &principal{UserID: user.ID, Method: authMethodSession, Role: auth.RoleModerator, EmailVerified: true}, got)
+
+	t.Run("Deleted user", func(t *testing.T) {
+		ghost, err := cfg.jwt_keys.MakeJWT(uuid.New(), time.Hour)
+		require.NoError(t, err)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+ghost)
+		rec := httptest.NewRecorder()
+		cfg.requireAuth("", func(w http.ResponseWriter, r *http.Request) {}).ServeHTTP(rec, req)
+		assert.Equal(t, 401, rec.Code)
+	})
+	t.Run("Demoted user", func(t *testing.T) {
+		err := cfg.dbq.SetUserRole(context.Background(), database.SetUserRoleParams{Role: auth.RoleUser, UpdatedAt: time.Now().UTC(), ID: user.ID})
+		require.NoError(t, err)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		cfg.requireRole(auth.RoleModerator, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("handler should not be called")
+		}).ServeHTTP(rec, req)
+		assert.Equal(t, 403, rec.Code)
+	})
+}
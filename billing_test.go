@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+	"github.com/bsuvonov/zingzing/internal/zingpay/zingpaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBillingCheckout(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	fake := zingpaytest.NewServer("sk_test", api.URL+"/api/zingpay/webhooks", testWebhookSecret)
+	t.Cleanup(fake.Close)
+	cfg.zingpay = zingpay.NewClient(fake.URL, "sk_test")
+	_, token := createTestUser(t, cfg)
+
+	type billing struct {
+		Plan             string            `json:"plan"`
+		Status           string            `json:"status"`
+		IsPremium        bool              `json:"is_premium"`
+		CurrentPeriodEnd *time.Time        `json:"current_period_end"`
+		Invoices         []zingpay.Invoice `json:"invoices"`
+	}
+	var before billing
+	resp := doJSON(t, "GET", api.URL+"/api/billing", token, nil, &before)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, billing{Plan: "free", Invoices: []zingpay.Invoice{}}, before)
+
+	resp = doJSON(t, "POST", api.URL+"/api/billing/checkout", token, map[string]string{"plan": "gold"}, nil)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	var session struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	resp = doJSON(t, "POST", api.URL+"/api/billing/checkout", token, nil, &session)
+	require.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, fake.URL+"/checkout/"+session.ID, session.URL)
+
+	// Pay on the hosted page, which delivers the webhook and sends the
+	// customer back to ZingZing.
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	payResp, err := noRedirects.Post(session.URL, "", nil)
+	require.NoError(t, err)
+	payResp.Body.Close()
+	require.Equal(t, 303, payResp.StatusCode)
+	assert.Equal(t, cfg.base_url+"/app/?checkout=success", payResp.Header.Get("Location"))
+	_, err = cfg.processWebhookEvents(context.Background())
+	require.NoError(t, err)
+
+	var after billing
+	resp = doJSON(t, "GET", api.URL+"/api/billing", token, nil, &after)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "red", after.Plan)
+	assert.Equal(t, "active", after.Status)
+	assert.True(t, after.IsPremium)
+	require.NotNil(t, after.CurrentPeriodEnd)
+	assert.True(t, after.CurrentPeriodEnd.After(time.Now()))
+	require.Len(t, after.Invoices, 1)
+	assert.Equal(t, "paid", after.Invoices[0].Status)
+
+	resp = doJSON(t, "POST", api.URL+"/api/billing/checkout", token, nil, nil)
+	assert.Equal(t, 409, resp.StatusCode, "already subscribed")
+
+	t.Run("Not configured", func(t *testing.T) {
+		cfg.zingpay = nil
+		resp := doJSON(t, "GET", api.URL+"/api/billing", token, nil, nil)
+		assert.Equal(t, 503, resp.StatusCode)
+	})
+}
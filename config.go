@@ -0,0 +1,153 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/bsuvonov/zingzing/internal/mailer"
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+)
+
+// envInt reads an integer setting, falling back to def when it is unset.
+func envInt(name string, def int) int {
+	val := os.Getenv(name)
+	if val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q: %s", name, val, err)
+		return def
+	}
+	return n
+}
+
+
+// envDuration reads a duration setting such as "30s" or "1h", falling back to
+// def when it is unset.
+func envDuration(name string, def time.Duration) time.Duration {
+	val := os.Getenv(name)
+	if val == "" {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q: %s", name, val, err)
+		return def
+	}
+	return d
+}
+
+
+// envBool reads a boolean setting, falling back to def when it is unset.
+func envBool(name string, def bool) bool {
+	val := os.Getenv(name)
+	if val == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q: %s", name, val, err)
+		return def
+	}
+	return b
+}
+
+
+// envString reads a string setting, falling back to def when it is unset.
+func envString(name, def string) string {
+	if val := os.Getenv(name); val != "" {
+		return val
+	}
+	return def
+}
+
+
+// loadMailer picks the mail transport from MAILER: "smtp" relays through
+// SMTP_HOST, anything else writes messages to MAIL_LOG_FILE (or the log).
+func loadMailer() mailer.Mailer {
+	from := envString("MAIL_FROM", "ZingZing <no-reply@zingzing.local>")
+	if os.Getenv("MAILER") == "smtp" {
+		return &mailer.SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     envInt("SMTP_PORT", 587),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+	return &mailer.LogMailer{Path: os.Getenv("MAIL_LOG_FILE"), From: from}
+}
+
+
+// loadLockoutPolicies returns the lockout policies for failed logins against a
+// single account and from a single client IP. The IP threshold is higher
+// because many people can share an address.
+func loadLockoutPolicies() (account auth.LockoutPolicy, ip auth.LockoutPolicy) {
+	base := envDuration("LOGIN_LOCKOUT_BASE", 30*time.Second)
+	max := envDuration("LOGIN_LOCKOUT_MAX", time.Hour)
+	window := envDuration("LOGIN_FAILURE_WINDOW", time.Hour)
+	account = auth.LockoutPolicy{Threshold: envInt("LOGIN_LOCKOUT_THRESHOLD", 5), BaseDelay: base, MaxDelay: max, Window: window}
+	ip = auth.LockoutPolicy{Threshold: envInt("LOGIN_IP_LOCKOUT_THRESHOLD", 20), BaseDelay: base, MaxDelay: max, Window: window}
+	return account, ip
+}
+
+
+// loadZingerLengthPolicy returns how long zingers of free and premium users
+// may be.
+func loadZingerLengthPolicy() zingerLengthPolicy {
+	return zingerLengthPolicy{
+		MaxLength:        envInt("ZINGER_MAX_LENGTH", 280),
+		PremiumMaxLength: envInt("ZINGER_MAX_LENGTH_PREMIUM", 1000),
+		URLLength:        envInt("ZINGER_URL_LENGTH", 23),
+	}
+}
+
+
+// loadZingerEditPolicy returns how long and how often zingers can be
+// edited after they are posted.
+func loadZingerEditPolicy() zingerEditPolicy {
+	return zingerEditPolicy{
+		Window:        envDuration("ZINGER_EDIT_WINDOW", 15*time.Minute),
+		PremiumWindow: envDuration("ZINGER_EDIT_WINDOW_PREMIUM", time.Hour),
+		MaxEdits:      envInt("ZINGER_MAX_EDITS", 5),
+	}
+}
+
+
+// loadPasswordResetPolicies returns how many password reset emails can be
+// asked for per email address from one client IP, and per client IP, in an
+// hour before further requests are refused.
+func loadPasswordResetPolicies() (email auth.LockoutPolicy, ip auth.LockoutPolicy) {
+	base := envDuration("PASSWORD_RESET_LOCKOUT", time.Hour)
+	email = auth.LockoutPolicy{Threshold: envInt("PASSWORD_RESET_EMAIL_LIMIT", 3), BaseDelay: base, MaxDelay: 24 * time.Hour, Window: time.Hour}
+	ip = auth.LockoutPolicy{Threshold: envInt("PASSWORD_RESET_IP_LIMIT", 10), BaseDelay: base, MaxDelay: 24 * time.Hour, Window: time.Hour}
+	return email, ip
+}
+
+
+// loadWebhookSecrets returns the secrets ZingPay webhooks may be signed with:
+// ZINGPAY_WEBHOOK_SECRET and, while rotating, ZINGPAY_WEBHOOK_PREVIOUS_SECRET.
+func loadWebhookSecrets() []string {
+	secrets := []string{}
+	for _, name := range []string{"ZINGPAY_WEBHOOK_SECRET", "ZINGPAY_WEBHOOK_PREVIOUS_SECRET"} {
+		if secret := os.Getenv(name); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+
+// loadZingPayClient returns a client for the ZingPay API at ZINGPAY_API_URL,
+// or nil when ZINGPAY_KEY is unset and billing is turned off.
+func loadZingPayClient() *zingpay.Client {
+	key := os.Getenv("ZINGPAY_KEY")
+	if key == "" {
+		return nil
+	}
+	return zingpay.NewClient(envString("ZINGPAY_API_URL", "https://api.zingpay.local"), key)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutUsers(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	user, token := createTestUser(t, cfg)
+	email := uuid.NewString() + "@example.com"
+
+	pat := createTestPersonalAccessToken(t, api, token, auth.ScopeProfileWrite)
+	resp := doJSON(t, "PUT", api.URL+"/api/users", pat, map[string]string{"email": email, "password": "hijacked", "current_password": "password"}, nil)
+	assert.Equal(t, 403, resp.StatusCode, "tokens can't change credentials")
+
+	resp = doJSON(t, "PUT", api.URL+"/api/users", token, map[string]string{"email": email, "password": "hijacked"}, nil)
+	assert.Equal(t, 403, resp.StatusCode, "without the current password")
+	resp = doJSON(t, "PUT", api.URL+"/api/users", token, map[string]string{"email": email, "password": "hijacked", "current_password": "wrong"}, nil)
+	assert.Equal(t, 403, resp.StatusCode, "with a wrong current password")
+	got, err := cfg.dbq.GetUserByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, got.Email)
+	assert.Equal(t, user.HashedPassword, got.HashedPassword)
+
+	resp = doJSON(t, "PUT", api.URL+"/api/users", token, map[string]string{"email": email, "password": "new password", "current_password": "password"}, nil)
+	require.Equal(t, 200, resp.StatusCode)
+	got, err = cfg.dbq.GetUserByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, email, got.Email)
+	assert.False(t, got.EmailVerifiedAt.Valid, "the new address needs verifying")
+	_, err = cfg.hasher.Check(got.HashedPassword, "new password")
+	assert.NoError(t, err)
+}
@@ -2,46 +2,316 @@ package main
 
 import (
 	"net/http"
-	"github.com/bsuvonov/zingzing/internal/auth"
 	"github.com/google/uuid"
-	"errors"
 	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"github.com/bsuvonov/zingzing/internal/database"
 )
 
 
 func (cfg *apiConfig) zingersDeleteHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+	caller := principalFrom(r)
+	userID := caller.UserID
+	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	zinger, err := qtx.GetZingerByIdForUpdate(context.Background(), zingerID)
+	if err != nil || zinger.DeletedAt.Valid {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+	if zinger.UserID != userID {
+		handleErrorForbidden(w)
+		return
+	}
+	// Nothing is left to share once it's gone.
+	err = qtx.DeleteRezingsOf(context.Background(), uuid.NullUUID{UUID: zingerID, Valid: true})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if zinger.ReplyCount > 0 || zinger.QuoteCount > 0 {
+		// Replies and quotes keep pointing at it, leave a tombstone in its
+		// place. Its earlier bodies go like the last one.
+		now := time.Now().UTC()
+		err = qtx.TombstoneZinger(context.Background(), database.TombstoneZingerParams{DeletedAt: sql.NullTime{Time: now, Valid: true}, UpdatedAt: now, ID: zingerID})
+		if err == nil {
+			err = qtx.DeleteZingerRevisions(context.Background(), zingerID)
+		}
+		if err == nil && zinger.QuoteOfID.Valid {
+			err = unquoteZinger(qtx, zinger.QuoteOfID.UUID)
+		}
+	} else {
+		err = deleteZinger(qtx, zinger)
+	}
+	if err == nil && zinger.RezingOfID.Valid {
+		err = qtx.DecrementRezingCount(context.Background(), zinger.RezingOfID.UUID)
+	}
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// deleteZinger removes zinger for good and takes it off the counts of the
+// zinger it replied to and the one it quoted. A tombstone was taken off the
+// quote count when it was left.
+func deleteZinger(qtx *database.Queries, zinger database.Zinger) error {
+	err := qtx.DeleteZingerById(context.Background(), zinger.ID)
+	if err != nil {
+		return err
+	}
+	if zinger.InReplyToID.Valid {
+		err = qtx.DecrementReplyCount(context.Background(), zinger.InReplyToID.UUID)
+		if err == nil {
+			err = deleteUnusedTombstone(qtx, zinger.InReplyToID.UUID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if zinger.QuoteOfID.Valid && !zinger.DeletedAt.Valid {
+		return unquoteZinger(qtx, zinger.QuoteOfID.UUID)
+	}
+	return nil
+}
+
+// unquoteZinger takes a quote off the quote count of the zinger it quoted.
+func unquoteZinger(qtx *database.Queries, quotedID uuid.UUID) error {
+	err := qtx.DecrementQuoteCount(context.Background(), quotedID)
+	if err != nil {
+		return err
+	}
+	return deleteUnusedTombstone(qtx, quotedID)
+}
+
+// deleteUnusedTombstone removes the zinger with the given id if it is a
+// tombstone that no reply or quote points at any more.
+func deleteUnusedTombstone(qtx *database.Queries, id uuid.UUID) error {
+	zinger, err := qtx.GetZingerByIdForUpdate(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if !zinger.DeletedAt.Valid || zinger.ReplyCount > 0 || zinger.QuoteCount > 0 {
+		return nil
+	}
+	return deleteZinger(qtx, zinger)
+}
+
+
+func (cfg *apiConfig) sessionDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	now := time.Now().UTC()
+	rows, err := cfg.dbq.RevokeSessionForUser(context.Background(), database.RevokeSessionForUserParams{UpdatedAt: now, RevokedAt: sql.NullTime{Time: now, Valid: true}, FamilyID: sessionID, UserID: userID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if rows == 0 {
+		handleErrorNotFound(w)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+
+func (cfg *apiConfig) sessionsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	now := time.Now().UTC()
+	err := cfg.dbq.RevokeAllRefreshTokensForUser(context.Background(), database.RevokeAllRefreshTokensForUserParams{UpdatedAt: now, RevokedAt: sql.NullTime{Time: now, Valid: true}, UserID: userID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+
+
+func (cfg *apiConfig) tokenDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	rows, err := cfg.dbq.RevokePersonalAccessToken(context.Background(), database.RevokePersonalAccessTokenParams{RevokedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true}, ID: tokenID, UserID: userID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if rows == 0 {
+		handleErrorNotFound(w)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+
+func (cfg *apiConfig) oauthClientDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	// Codes, consents and refresh tokens of the client go with it.
+	rows, err := cfg.dbq.DeleteOAuthClient(context.Background(), database.DeleteOAuthClientParams{ID: clientID, OwnerID: userID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if rows == 0 {
+		handleErrorNotFound(w)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+
+// followDeleteHandler makes the caller stop following the user in the path,
+// if they did.
+func (cfg *apiConfig) followDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
 	if err != nil {
-		handleErrorUnauthorized(w)
+		handleErrorNotFound(w)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
 	if err != nil {
-		if errors.Is(err, auth.ErrInvalidJWT) {
-			handleErrorUnauthorized(w)
-		} else {
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	rows, err := qtx.UnfollowUser(context.Background(), database.UnfollowUserParams{FollowerID: userID, FolloweeID: followeeID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if rows == 1 {
+		err = qtx.AddUserFollowCounts(context.Background(), database.AddUserFollowCountsParams{FolloweeID: followeeID, Delta: -1, FollowerID: userID})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		err = qtx.RemoveAuthorFromTimeline(context.Background(), database.RemoveAuthorFromTimelineParams{UserID: userID, AuthorID: followeeID})
+		if err != nil {
 			handleError(w, r, err)
+			return
 		}
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
 		return
 	}
+	w.WriteHeader(204)
+}
+
+// likeDeleteHandler takes back the caller's like of the zinger in the path,
+// if there was one.
+func (cfg *apiConfig) likeDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
 	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
 	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	rows, err := qtx.UnlikeZinger(context.Background(), database.UnlikeZingerParams{UserID: userID, ZingerID: zingerID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if rows == 1 {
+		err = qtx.DecrementLikeCount(context.Background(), zingerID)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
 		handleError(w, r, err)
 		return
 	}
-	zinger, err := cfg.dbq.GetZingerById(context.Background(), zingerID)
+	w.WriteHeader(204)
+}
+
+// rezingDeleteHandler takes back the caller's rezing of the zinger in the
+// path, if there was one.
+func (cfg *apiConfig) rezingDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
 	if err != nil {
 		handleErrorNotFound(w)
 		return
 	}
-	if zinger.UserID != userID {
-		handleErrorForbidden(w)
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
-	err = cfg.dbq.DeleteZingerById(context.Background(), zingerID)
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	// The zinger is locked before the rezing, in the same order as when it
+	// is deleted along with its rezings.
+	err = qtx.DecrementRezingCount(context.Background(), zingerID)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
+	_, err = qtx.DeleteRezing(context.Background(), database.DeleteRezingParams{UserID: userID, RezingOfID: uuid.NullUUID{UUID: zingerID, Valid: true}})
+	if errors.Is(err, sql.ErrNoRows) {
+		// There was none; rolling back undoes the count.
+		w.WriteHeader(204)
+		return
+	}
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
 	w.WriteHeader(204)
-}
\ No newline at end of file
+}
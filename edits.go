@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/database"
+)
+
+var (
+	errEditWindowClosed = errors.New("zinger can no longer be edited")
+	errTooManyEdits     = errors.New("zinger was edited too many times")
+)
+
+// zingerEditPolicy is how long after posting, and how often, authors may
+// edit their zingers. Premium users get a longer window.
+type zingerEditPolicy struct {
+	Window        time.Duration
+	PremiumWindow time.Duration
+	MaxEdits      int
+}
+
+// check returns why zinger can't be edited at now, or nil if it can.
+func (p zingerEditPolicy) check(zinger database.Zinger, premium bool, now time.Time) error {
+	window := p.Window
+	if premium {
+		window = p.PremiumWindow
+	}
+	if now.Sub(zinger.CreatedAt) > window {
+		return errEditWindowClosed
+	}
+	if int(zinger.EditCount) >= p.MaxEdits {
+		return errTooManyEdits
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZingerEditPolicy(t *testing.T) {
+	policy := zingerEditPolicy{Window: 15 * time.Minute, PremiumWindow: time.Hour, MaxEdits: 2}
+	posted := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	zinger := database.Zinger{CreatedAt: posted}
+
+	assert.NoError(t, policy.check(zinger, false, posted.Add(15*time.Minute)))
+	assert.ErrorIs(t, policy.check(zinger, false, posted.Add(16*time.Minute)), errEditWindowClosed)
+	assert.NoError(t, policy.check(zinger, true, posted.Add(16*time.Minute)), "premium")
+	assert.ErrorIs(t, policy.check(zinger, true, posted.Add(61*time.Minute)), errEditWindowClosed)
+
+	zinger.EditCount = 2
+	assert.ErrorIs(t, policy.check(zinger, false, posted), errTooManyEdits)
+}
+
+func TestZingerEdits(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	cfg.zinger_edits = zingerEditPolicy{Window: time.Hour, PremiumWindow: time.Hour, MaxEdits: 2}
+	_, aliceToken := createTestUser(t, cfg)
+	_, bobToken := createTestUser(t, cfg)
+
+	var zinger zingerResponse
+	resp := doJSON(t, "POST", api.URL+"/api/zingers", aliceToken, map[string]string{"body": "frist"}, &zinger)
+	require.Equal(t, 201, resp.StatusCode)
+	zingerURL := api.URL + "/api/zingers/" + zinger.Id.String()
+
+	resp = doJSON(t, "PATCH", zingerURL, bobToken, map[string]string{"body": "mine now"}, nil)
+	assert.Equal(t, 403, resp.StatusCode, "not the author")
+
+	var edited zingerResponse
+	resp = doJSON(t, "PATCH", zingerURL, aliceToken, map[string]string{"body": "first"}, &edited)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "first", edited.Body)
+	assert.Equal(t, int32(1), edited.EditCount)
+	resp = doJSON(t, "PATCH", zingerURL, aliceToken, map[string]string{"body": "first"}, &edited)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, int32(1), edited.EditCount, "unchanged body isn't an edit")
+	resp = doJSON(t, "PATCH", zingerURL, aliceToken, map[string]string{"body": "first!"}, &edited)
+	require.Equal(t, 200, resp.StatusCode)
+	resp = doJSON(t, "PATCH", zingerURL, aliceToken, map[string]string{"body": "first!!"}, nil)
+	assert.Equal(t, 403, resp.StatusCode, "edit limit")
+
+	var history struct {
+		Zinger    zingerResponse `json:"zinger"`
+		Revisions []struct {
+			Revision int32  `json:"revision"`
+			Body     string `json:"body"`
+		} `json:"revisions"`
+	}
+	resp = doJSON(t, "GET", zingerURL+"/history", "", nil, &history)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "first!", history.Zinger.Body)
+	require.Len(t, history.Revisions, 2)
+	assert.Equal(t, "frist", history.Revisions[0].Body)
+	assert.Equal(t, "first", history.Revisions[1].Body)
+	assert.Equal(t, int32(1), history.Revisions[1].Revision)
+
+	t.Run("Censored", func(t *testing.T) {
+		var got zingerResponse
+		resp := doJSON(t, "POST", api.URL+"/api/zingers", aliceToken, map[string]string{"body": "clean"}, &got)
+		require.Equal(t, 201, resp.StatusCode)
+		resp = doJSON(t, "PATCH", api.URL+"/api/zingers/"+got.Id.String(), aliceToken, map[string]string{"body": "what a stupid idea"}, &got)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "what a **** idea", got.Body)
+	})
+
+}
+
+func TestZingerEditWindow(t *testing.T) {
+	// Timestamps are stored without a time zone, so a server west of UTC
+	// would see zingers as older than they are if it wrote local times.
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	cfg, api := newTestAPI(t)
+	cfg.zinger_edits = zingerEditPolicy{Window: time.Hour, PremiumWindow: time.Hour, MaxEdits: 5}
+	_, token := createTestUser(t, cfg)
+
+	var zinger zingerResponse
+	resp := doJSON(t, "POST", api.URL+"/api/zingers", token, map[string]string{"body": "fresh"}, &zinger)
+	require.Equal(t, 201, resp.StatusCode)
+	zingerURL := api.URL + "/api/zingers/" + zinger.Id.String()
+	resp = doJSON(t, "PATCH", zingerURL, token, map[string]string{"body": "still fresh"}, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	_, err := cfg.db.Exec("UPDATE zingers SET created_at = $1 WHERE id = $2", time.Now().UTC().Add(-61*time.Minute), zinger.Id)
+	require.NoError(t, err)
+	resp = doJSON(t, "PATCH", zingerURL, token, map[string]string{"body": "stale"}, nil)
+	assert.Equal(t, 403, resp.StatusCode, "window closed")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailVerification(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	cfg.unverified_read_only = true
+	user, token := createTestUser(t, cfg)
+	unverifyTestUser(t, cfg, user.ID)
+
+	t.Run("Read-only", func(t *testing.T) {
+		zingerURL := api.URL + "/api/zingers/" + uuid.NewString()
+		userURL := api.URL + "/api/users/" + uuid.NewString()
+		for _, route := range []struct{ method, url string }{
+			{"POST", api.URL + "/api/zingers"},
+			{"PATCH", zingerURL},
+			{"DELETE", zingerURL},
+			{"DELETE", zingerURL + "/like"},
+			{"DELETE", zingerURL + "/rezing"},
+			{"DELETE", userURL + "/follow"},
+			{"PATCH", api.URL + "/api/users/me"},
+			{"POST", api.URL + "/api/tokens"},
+		} {
+			resp := doJSON(t, route.method, route.url, token, map[string]string{}, nil)
+			assert.Equal(t, 403, resp.StatusCode, route.method+" "+route.url)
+		}
+		resp := doJSON(t, "GET", api.URL+"/api/zingers", token, nil, nil)
+		assert.Equal(t, 200, resp.StatusCode, "reading still works")
+	})
+
+	resp := doJSON(t, "POST", api.URL+"/api/users/verify/resend", token, nil, nil)
+	require.Equal(t, 204, resp.StatusCode)
+	first := mailedToken(t, cfg, user.Email)
+	require.NotEmpty(t, first)
+	resp = doJSON(t, "POST", api.URL+"/api/users/verify/resend", token, nil, nil)
+	require.Equal(t, 204, resp.StatusCode)
+	verification := mailedToken(t, cfg, user.Email)
+	require.NotEqual(t, first, verification)
+
+	resp = doJSON(t, "POST", api.URL+"/api/users/verify", "", map[string]string{"token": first}, nil)
+	assert.Equal(t, 400, resp.StatusCode, "a resend replaces the earlier link")
+	resp = doJSON(t, "POST", api.URL+"/api/users/verify", "", map[string]string{"token": "0123abcd"}, nil)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	var verified struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	resp = doJSON(t, "POST", api.URL+"/api/users/verify", "", map[string]string{"token": verification}, &verified)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, user.Email, verified.Email)
+	assert.True(t, verified.EmailVerified)
+	resp = doJSON(t, "POST", api.URL+"/api/users/verify", "", map[string]string{"token": verification}, nil)
+	assert.Equal(t, 400, resp.StatusCode, "links work once")
+
+	resp = doJSON(t, "POST", api.URL+"/api/zingers", token, map[string]string{"body": "verified at last"}, nil)
+	assert.Equal(t, 201, resp.StatusCode)
+	resp = doJSON(t, "POST", api.URL+"/api/users/verify/resend", token, nil, nil)
+	assert.Equal(t, 409, resp.StatusCode)
+}
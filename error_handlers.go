@@ -4,6 +4,9 @@ import (
 	"net/http"
 	"encoding/json"
 	"log"
+	"math"
+	"strconv"
+	"time"
 )
 
 func handleError(w http.ResponseWriter, r *http.Request, err error) {
@@ -51,3 +54,31 @@ func handleErrorNotFound(w http.ResponseWriter) {
 	w.Write([]byte("Not Found"))
 }
 
+
+func respondWithError(w http.ResponseWriter, code int, msg string) {
+	type returnVals struct {
+		Err string `json:"error"`
+	}
+	respondWithJSON(w, code, returnVals{Err: msg})
+}
+
+func handleErrorTooManyRequests(w http.ResponseWriter, msg string, retryAfter time.Duration) {
+	type returnVals struct {
+		Err        string `json:"error"`
+		RetryAfter int    `json:"retry_after"`
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	respondWithJSON(w, 429, returnVals{Err: msg, RetryAfter: seconds})
+}
+
+// respondWithOAuthError writes an OAuth 2.0 error response (RFC 6749,
+// section 5.2).
+func respondWithOAuthError(w http.ResponseWriter, code int, oauthErr, description string) {
+	type returnVals struct {
+		Err         string `json:"error"`
+		Description string `json:"error_description,omitempty"`
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, returnVals{Err: oauthErr, Description: description})
+}
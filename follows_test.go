@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// followsPage is the response of the followers and following lists.
+type followsPage struct {
+	Users      []userResponse `json:"users"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+func TestFollows(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	alice, aliceToken := createTestUser(t, cfg)
+	bob, bobToken := createTestUser(t, cfg)
+	carol, carolToken := createTestUser(t, cfg)
+	follow := func(token string, userID uuid.UUID) userResponse {
+		t.Helper()
+		var got userResponse
+		resp := doJSON(t, "POST", api.URL+"/api/users/"+userID.String()+"/follow", token, nil, &got)
+		require.Equal(t, 200, resp.StatusCode)
+		return got
+	}
+	list := func(token, path string) followsPage {
+		t.Helper()
+		var page followsPage
+		resp := doJSON(t, "GET", api.URL+path, token, nil, &page)
+		require.Equal(t, 200, resp.StatusCode)
+		return page
+	}
+
+	follow(bobToken, alice.ID)
+	got := follow(carolToken, alice.ID)
+	assert.Equal(t, int32(2), got.FollowerCount)
+	got = follow(carolToken, alice.ID)
+	assert.Equal(t, int32(2), got.FollowerCount, "following twice counts once")
+	follow(aliceToken, bob.ID)
+
+	resp := doJSON(t, "POST", api.URL+"/api/users/"+alice.ID.String()+"/follow", aliceToken, nil, nil)
+	assert.Equal(t, 400, resp.StatusCode, "yourself")
+	resp = doJSON(t, "POST", api.URL+"/api/users/"+uuid.NewString()+"/follow", aliceToken, nil, nil)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	t.Run("Followers", func(t *testing.T) {
+		page := list("", "/api/users/"+alice.ID.String()+"/followers?limit=1")
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, carol.ID, page.Users[0].ID, "newest first")
+		assert.Nil(t, page.Users[0].ViewerFollows, "anonymous")
+		require.NotNil(t, page.NextCursor)
+
+		page = list("", "/api/users/"+alice.ID.String()+"/followers?limit=1&cursor="+*page.NextCursor)
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, bob.ID, page.Users[0].ID)
+		assert.Equal(t, int32(1), page.Users[0].FollowerCount)
+		assert.Equal(t, int32(1), page.Users[0].FollowingCount)
+		assert.Nil(t, page.NextCursor)
+
+		page = list(aliceToken, "/api/users/"+alice.ID.String()+"/followers")
+		viewerFollows := map[uuid.UUID]bool{}
+		for _, u := range page.Users {
+			require.NotNil(t, u.ViewerFollows)
+			viewerFollows[u.ID] = *u.ViewerFollows
+		}
+		assert.Equal(t, map[uuid.UUID]bool{bob.ID: true, carol.ID: false}, viewerFollows)
+	})
+
+	t.Run("Following", func(t *testing.T) {
+		page := list("", "/api/users/"+carol.ID.String()+"/following")
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, alice.ID, page.Users[0].ID)
+		assert.Equal(t, int32(2), page.Users[0].FollowerCount)
+	})
+
+	t.Run("Unfollow", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			resp := doJSON(t, "DELETE", api.URL+"/api/users/"+alice.ID.String()+"/follow", carolToken, nil, nil)
+			assert.Equal(t, 204, resp.StatusCode)
+		}
+		page := list("", "/api/users/"+alice.ID.String()+"/followers")
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, bob.ID, page.Users[0].ID)
+		assert.Empty(t, list("", "/api/users/"+carol.ID.String()+"/following").Users)
+	})
+
+	resp = doJSON(t, "GET", api.URL+"/api/users/"+uuid.NewString()+"/followers", "", nil, nil)
+	assert.Equal(t, 404, resp.StatusCode)
+}
@@ -9,7 +9,13 @@ import (
 	"github.com/bsuvonov/zingzing/internal/database"
 	"time"
 	"fmt"
-	"sort"
+	"errors"
+	"database/sql"
+	"slices"
+	"strings"
+	"strconv"
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/bsuvonov/zingzing/internal/zingpay"
 )
 
 
@@ -22,6 +28,10 @@ func handlerHealthz(w http.ResponseWriter, r *http.Request) {
 
 
 func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := recordAdminAction(context.Background(), cfg.dbq, newAdminAction(r, "view_metrics")); err != nil {
+		handleError(w, r, err)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(200)
 
@@ -39,93 +49,976 @@ func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 
+func (cfg *apiConfig) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, 200, cfg.jwt_keys.JWKS())
+}
+
+
+// zingersGetHandler lists zingers a page at a time, oldest first unless
+// sort=desc. author_id, since and until narrow the list down; the next page
+// starts after the next_cursor of the previous one.
 func (cfg *apiConfig) zingersGetHandler(w http.ResponseWriter, r *http.Request) {
-	authorIDParam := r.URL.Query().Get("author_id")
+	query := r.URL.Query()
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.ListZingersAscParams{RowLimit: int32(page.Limit + 1)}
+	if s := query.Get("author_id"); s != "" {
+		authorID, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, 400, "invalid author_id")
+			return
+		}
+		params.UserID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+	for name, dst := range map[string]*sql.NullTime{"since": &params.Since, "until": &params.Until} {
+		if s := query.Get(name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				respondWithError(w, 400, name+" must be an RFC 3339 timestamp")
+				return
+			}
+			*dst = sql.NullTime{Time: t.UTC(), Valid: true}
+		}
+	}
+	if page.After != nil {
+		params.AfterCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
 
 	var zingers []database.Zinger
 	var err error
+	switch query.Get("sort") {
+	case "", "asc":
+		zingers, err = cfg.dbq.ListZingersAsc(context.Background(), params)
+	case "desc":
+		zingers, err = cfg.dbq.ListZingersDesc(context.Background(), database.ListZingersDescParams(params))
+	default:
+		respondWithError(w, 400, "sort must be asc or desc")
+		return
+	}
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	zingers, nextCursor := nextPageCursor(zingers, page.Limit, func(z database.Zinger) pageCursor {
+		return pageCursor{CreatedAt: z.CreatedAt, ID: z.ID}
+	})
 
-	authorID, parseErr := uuid.Parse(authorIDParam)
-	if parseErr != nil {
-		zingers, err = cfg.dbq.GetAllZingers(context.Background())
-	} else {
-		zingers, err = cfg.dbq.GetZingersByUser(context.Background(), authorID)
+	type returnVals struct {
+		Zingers    []zingerResponse `json:"zingers"`
+		NextCursor *string          `json:"next_cursor"`
 	}
+	respBody := returnVals{Zingers: newZingerResponses(zingers), NextCursor: nextCursor}
+	if err := cfg.completeZingerResponses(r, zingerPointers(respBody.Zingers)...); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, respBody)
+}
+
+
+
+
+func (cfg *apiConfig) zingerGetHandler(w http.ResponseWriter, r *http.Request) {
+	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
 
-	sortOrder := r.URL.Query().Get("sort")
-	if sortOrder == "desc" {
-		sort.Slice(zingers, func(i, j int) bool { return zingers[i].CreatedAt.UTC().After(zingers[j].CreatedAt.UTC())})
+	zinger, err := cfg.dbq.GetZingerById(context.Background(), zingerID)
+	if err!= nil || zinger.DeletedAt.Valid {
+		handleErrorNotFound(w)
+		return
+	}
+
+	respBody := newZingerResponse(zinger)
+	if err := cfg.completeZingerResponses(r, &respBody); err != nil {
+		handleError(w, r, err)
+		return
 	}
+	respondWithJSON(w, 200, respBody)
+}
+
+
 
+// zingerLimitsGetHandler publishes the length limits of zingers, so clients
+// can count characters the way the server does. max_length is the caller's
+// own limit.
+func (cfg *apiConfig) zingerLimitsGetHandler(w http.ResponseWriter, r *http.Request) {
+	premium := false
+	if caller := principalFrom(r); caller != nil {
+		premium = caller.IsPremium
+	}
 	type returnVals struct {
-		Id        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
+		MaxLength        int    `json:"max_length"`
+		FreeMaxLength    int    `json:"free_max_length"`
+		PremiumMaxLength int    `json:"premium_max_length"`
+		URLLength        int    `json:"url_length"`
+		Counting         string `json:"counting"`
+	}
+	respondWithJSON(w, 200, returnVals{
+		MaxLength:        cfg.zinger_length.maxLength(premium),
+		FreeMaxLength:    cfg.zinger_length.MaxLength,
+		PremiumMaxLength: cfg.zinger_length.PremiumMaxLength,
+		URLLength:        cfg.zinger_length.URLLength,
+		Counting:         "graphemes",
+	})
+}
+
+// zingerHistoryGetHandler shows a zinger as it is now, with the bodies it had
+// before it was edited, oldest first.
+func (cfg *apiConfig) zingerHistoryGetHandler(w http.ResponseWriter, r *http.Request) {
+	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	zinger, err := cfg.dbq.GetZingerById(context.Background(), zingerID)
+	if err != nil || zinger.DeletedAt.Valid {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+	revisions, err := cfg.dbq.ListZingerRevisions(context.Background(), zingerID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	type revisionVals struct {
+		Revision  int32     `json:"revision"`
 		Body      string    `json:"body"`
-		UserId    uuid.UUID `json:"user_id"`
+		CreatedAt time.Time `json:"created_at"`
 	}
+	type returnVals struct {
+		Zinger    zingerResponse `json:"zinger"`
+		Revisions []revisionVals `json:"revisions"`
+	}
+	respBody := returnVals{Zinger: newZingerResponse(zinger), Revisions: make([]revisionVals, len(revisions))}
+	for i, revision := range revisions {
+		respBody.Revisions[i] = revisionVals{Revision: revision.Revision, Body: revision.Body, CreatedAt: revision.CreatedAt}
+	}
+	if err := cfg.completeZingerResponses(r, &respBody.Zinger); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	respondWithJSON(w, 200, respBody)
+}
+
+// zingerResponse is how a zinger is shown. Deleted zingers that were replied
+// to or quoted stay as empty tombstones. Rezings and quotes carry the zinger
+// they share.
+type zingerResponse struct {
+	Id             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Body           string     `json:"body"`
+	UserId         uuid.UUID  `json:"user_id"`
+	InReplyToID    *uuid.UUID `json:"in_reply_to_id"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	ReplyCount     int32      `json:"reply_count"`
+	LikeCount      int32      `json:"like_count"`
+	RezingCount    int32      `json:"rezing_count"`
+	QuoteCount     int32      `json:"quote_count"`
+	EditCount      int32      `json:"edit_count"`
+	RezingOfID     *uuid.UUID `json:"rezing_of_id,omitempty"`
+	QuoteOfID      *uuid.UUID `json:"quote_of_id,omitempty"`
+	Deleted        bool       `json:"deleted,omitempty"`
+	ViewerLiked    *bool      `json:"viewer_liked,omitempty"`
+	LikedAt        *time.Time `json:"liked_at,omitempty"`
+
+	RezingOf *zingerResponse `json:"rezing_of,omitempty"`
+	QuoteOf  *zingerResponse `json:"quote_of,omitempty"`
+}
 
-	jsonZingers := make([]returnVals, len(zingers))
+func newZingerResponse(zinger database.Zinger) zingerResponse {
+	resp := zingerResponse{
+		Id:             zinger.ID,
+		CreatedAt:      zinger.CreatedAt,
+		UpdatedAt:      zinger.UpdatedAt,
+		Body:           zinger.Body,
+		UserId:         zinger.UserID,
+		ConversationID: zinger.ConversationID,
+		ReplyCount:     zinger.ReplyCount,
+		LikeCount:      zinger.LikeCount,
+		RezingCount:    zinger.RezingCount,
+		QuoteCount:     zinger.QuoteCount,
+		EditCount:      zinger.EditCount,
+		Deleted:        zinger.DeletedAt.Valid,
+	}
+	if zinger.InReplyToID.Valid {
+		resp.InReplyToID = &zinger.InReplyToID.UUID
+	}
+	if zinger.RezingOfID.Valid {
+		resp.RezingOfID = &zinger.RezingOfID.UUID
+	}
+	if zinger.QuoteOfID.Valid {
+		resp.QuoteOfID = &zinger.QuoteOfID.UUID
+	}
+	return resp
+}
 
+func newZingerResponses(zingers []database.Zinger) []zingerResponse {
+	resp := make([]zingerResponse, len(zingers))
 	for i, zinger := range zingers {
-		jsonZingers[i] = returnVals{
-			Id:        zinger.ID,
-			CreatedAt: zinger.CreatedAt,
-			UpdatedAt: zinger.UpdatedAt,
-			Body:      zinger.Body,
-			UserId:    zinger.UserID,
-		}
+		resp[i] = newZingerResponse(zinger)
 	}
-	fmt.Println("AUTHOR:" + authorIDParam)
-	fmt.Printf("zingers:")
-	fmt.Println(zingers)
-	fmt.Println("jsonZingers:")
-	fmt.Println(jsonZingers)
+	return resp
+}
 
+func zingerPointers(zingers []zingerResponse) []*zingerResponse {
+	ptrs := make([]*zingerResponse, len(zingers))
+	for i := range zingers {
+		ptrs[i] = &zingers[i]
+	}
+	return ptrs
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(jsonZingers)
+// completeZingerResponses fills in what zingers are shown with beyond their
+// own row: the zingers rezinged or quoted, and whether the caller liked them.
+func (cfg *apiConfig) completeZingerResponses(r *http.Request, zingers ...*zingerResponse) error {
+	shared, err := cfg.embedSharedZingers(zingers)
+	if err != nil {
+		return err
+	}
+	return cfg.setViewerLiked(r, append(zingers, shared...)...)
 }
 
+// embedSharedZingers looks up the zingers that rezings and quotes point at,
+// all in one query, and returns them. Quoted zingers that were deleted since
+// come back as tombstones.
+func (cfg *apiConfig) embedSharedZingers(zingers []*zingerResponse) ([]*zingerResponse, error) {
+	var ids []uuid.UUID
+	for _, zinger := range zingers {
+		if zinger.RezingOfID != nil {
+			ids = append(ids, *zinger.RezingOfID)
+		}
+		if zinger.QuoteOfID != nil {
+			ids = append(ids, *zinger.QuoteOfID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	found, err := cfg.dbq.GetZingersByIds(context.Background(), ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]database.Zinger, len(found))
+	for _, zinger := range found {
+		byID[zinger.ID] = zinger
+	}
 
+	var shared []*zingerResponse
+	embed := func(id *uuid.UUID) *zingerResponse {
+		if id == nil {
+			return nil
+		}
+		zinger, ok := byID[*id]
+		if !ok {
+			return nil
+		}
+		resp := newZingerResponse(zinger)
+		shared = append(shared, &resp)
+		return &resp
+	}
+	for _, zinger := range zingers {
+		zinger.RezingOf = embed(zinger.RezingOfID)
+		zinger.QuoteOf = embed(zinger.QuoteOfID)
+	}
+	return shared, nil
+}
 
+// setViewerLiked tells signed in callers which of the zingers they liked,
+// with one query for all of them. Anonymous callers see no viewer_liked.
+func (cfg *apiConfig) setViewerLiked(r *http.Request, zingers ...*zingerResponse) error {
+	caller := principalFrom(r)
+	if caller == nil || len(zingers) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(zingers))
+	for i, zinger := range zingers {
+		ids[i] = zinger.Id
+	}
+	likedIDs, err := cfg.dbq.GetLikedZingerIDs(context.Background(), database.GetLikedZingerIDsParams{UserID: caller.UserID, ZingerIds: ids})
+	if err != nil {
+		return err
+	}
+	for _, zinger := range zingers {
+		liked := slices.Contains(likedIDs, zinger.Id)
+		zinger.ViewerLiked = &liked
+	}
+	return nil
+}
 
-func (cfg *apiConfig) zingerGetHandler(w http.ResponseWriter, r *http.Request) {
+
+
+// zingerContextGetHandler shows the conversation around a zinger: the chain
+// of zingers it replies to, root first, and a page of the replies below it.
+// Replies come depth first, each after the one it answers, with their depth
+// below the zinger.
+func (cfg *apiConfig) zingerContextGetHandler(w http.ResponseWriter, r *http.Request) {
 	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	zinger, err := cfg.dbq.GetZingerById(context.Background(), zingerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+	ancestors, err := cfg.dbq.GetZingerAncestors(context.Background(), zingerID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	params := database.GetZingerDescendantsParams{ZingerID: zingerID, RowLimit: int32(page.Limit + 1)}
+	if page.After != nil {
+		params.AfterID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	descendants, err := cfg.dbq.GetZingerDescendants(context.Background(), params)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
+	descendants, nextCursor := nextPageCursor(descendants, page.Limit, func(d database.GetZingerDescendantsRow) pageCursor {
+		return pageCursor{CreatedAt: d.CreatedAt, ID: d.ID}
+	})
 
-	zinger, err := cfg.dbq.GetZingerById(context.Background(), zingerID)
-	if err!= nil {
-		handleErrorNotFound(w)
+	type descendantVals struct {
+		zingerResponse
+		Depth int32 `json:"depth"`
+	}
+	type returnVals struct {
+		Zinger      zingerResponse   `json:"zinger"`
+		Ancestors   []zingerResponse `json:"ancestors"`
+		Descendants []descendantVals `json:"descendants"`
+		NextCursor  *string          `json:"next_cursor"`
+	}
+	respBody := returnVals{Zinger: newZingerResponse(zinger), Ancestors: newZingerResponses(ancestors), Descendants: make([]descendantVals, len(descendants)), NextCursor: nextCursor}
+	for i, d := range descendants {
+		respBody.Descendants[i] = descendantVals{zingerResponse: newZingerResponse(database.Zinger{
+			ID:             d.ID,
+			CreatedAt:      d.CreatedAt,
+			UpdatedAt:      d.UpdatedAt,
+			Body:           d.Body,
+			UserID:         d.UserID,
+			InReplyToID:    d.InReplyToID,
+			ConversationID: d.ConversationID,
+			ReplyCount:     d.ReplyCount,
+			DeletedAt:      d.DeletedAt,
+			LikeCount:      d.LikeCount,
+			RezingOfID:     d.RezingOfID,
+			QuoteOfID:      d.QuoteOfID,
+			RezingCount:    d.RezingCount,
+			QuoteCount:     d.QuoteCount,
+			EditCount:      d.EditCount,
+		}), Depth: d.Depth}
+	}
+	shown := append([]*zingerResponse{&respBody.Zinger}, zingerPointers(respBody.Ancestors)...)
+	for i := range respBody.Descendants {
+		shown = append(shown, &respBody.Descendants[i].zingerResponse)
+	}
+	if err := cfg.completeZingerResponses(r, shown...); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	respondWithJSON(w, 200, respBody)
+}
+
+
+
+
+
+func (cfg *apiConfig) sessionsGetHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+
+	sessions, err := cfg.dbq.GetActiveSessionsByUser(context.Background(), database.GetActiveSessionsByUserParams{UserID: userID, ExpiresAt: time.Now().UTC()})
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
 
 	type returnVals struct {
-		ID uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body string `json:"body"`
-		UserId uuid.UUID `json:"user_id"`
+		ID         uuid.UUID `json:"id"`
+		CreatedAt  time.Time `json:"created_at"`
+		LastUsedAt time.Time `json:"last_used_at"`
+		ExpiresAt  time.Time `json:"expires_at"`
+		UserAgent  string    `json:"user_agent"`
+		IPAddress  string    `json:"ip_address"`
+		ClientID   uuid.NullUUID `json:"client_id"`
 	}
-	
-	respBody := returnVals{ID: zinger.ID, CreatedAt: zinger.CreatedAt, UpdatedAt: zinger.UpdatedAt, Body: zinger.Body, UserId: zinger.UserID}
 
-	w.WriteHeader(200)
-	w.Header().Set("Content-Type", "application/json")
-	dat, err := json.Marshal(respBody)
+	respBody := make([]returnVals, len(sessions))
+	for i, session := range sessions {
+		respBody[i] = returnVals{
+			ID:         session.FamilyID,
+			CreatedAt:  session.SessionStartedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+			ClientID:   session.ClientID,
+		}
+	}
+	respondWithJSON(w, 200, respBody)
+}
+
+
+
+// personalAccessTokenResponse is how a personal access token is shown to its
+// owner. The token itself is only ever returned once, when it is created.
+type personalAccessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func newPersonalAccessTokenResponse(pat database.PersonalAccessToken) personalAccessTokenResponse {
+	resp := personalAccessTokenResponse{ID: pat.ID, Name: pat.Name, Scopes: pat.Scopes, CreatedAt: pat.CreatedAt, ExpiresAt: pat.ExpiresAt}
+	if pat.LastUsedAt.Valid {
+		resp.LastUsedAt = &pat.LastUsedAt.Time
+	}
+	return resp
+}
+
+func (cfg *apiConfig) tokensGetHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	tokens, err := cfg.dbq.GetPersonalAccessTokensByUser(context.Background(), userID)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
-	w.Write(dat)
+	respBody := make([]personalAccessTokenResponse, len(tokens))
+	for i, pat := range tokens {
+		respBody[i] = newPersonalAccessTokenResponse(pat)
+	}
+	respondWithJSON(w, 200, respBody)
+}
+
+
+// oauthClientResponse is how an OAuth client is shown to its owner. The
+// secret of a confidential client is only returned when it is registered.
+type oauthClientResponse struct {
+	ID           uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+	ClientSecret string    `json:"client_secret,omitempty"`
 }
 
+func newOAuthClientResponse(client database.OauthClient) oauthClientResponse {
+	return oauthClientResponse{ID: client.ID, Name: client.Name, RedirectURIs: client.RedirectUris, Confidential: client.SecretHash.Valid, CreatedAt: client.CreatedAt}
+}
 
+func (cfg *apiConfig) oauthClientsGetHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	clients, err := cfg.dbq.GetOAuthClientsByOwner(context.Background(), userID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	respBody := make([]oauthClientResponse, len(clients))
+	for i, client := range clients {
+		respBody[i] = newOAuthClientResponse(client)
+	}
+	respondWithJSON(w, 200, respBody)
+}
+
+
+// authorizeRequest holds the parameters of an OAuth authorization request
+// (RFC 6749, section 4.1.1, with PKCE from RFC 7636).
+type authorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// checkAuthorizeRequest validates req against the client it names and returns
+// the client and the requested scopes. Problems are reported to the user with
+// a 400 rather than sent to a redirect URI, which may not be the client's.
+func (cfg *apiConfig) checkAuthorizeRequest(w http.ResponseWriter, r *http.Request, req authorizeRequest) (database.OauthClient, []string, bool) {
+	clientID, err := uuid.Parse(req.ClientID)
+	if err != nil {
+		respondWithOAuthError(w, 400, "invalid_request", "unknown client_id")
+		return database.OauthClient{}, nil, false
+	}
+	client, err := cfg.dbq.GetOAuthClient(context.Background(), clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithOAuthError(w, 400, "invalid_request", "unknown client_id")
+		} else {
+			handleError(w, r, err)
+		}
+		return database.OauthClient{}, nil, false
+	}
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		respondWithOAuthError(w, 400, "invalid_request", "redirect_uri is not registered for this client")
+		return database.OauthClient{}, nil, false
+	}
+	if req.ResponseType != "code" {
+		respondWithOAuthError(w, 400, "unsupported_response_type", "")
+		return database.OauthClient{}, nil, false
+	}
+	if req.CodeChallengeMethod != auth.PKCEMethodS256 || len(req.CodeChallenge) != 43 {
+		respondWithOAuthError(w, 400, "invalid_request", "PKCE with the S256 method is required")
+		return database.OauthClient{}, nil, false
+	}
+	scopes := strings.Fields(req.Scope)
+	if err := auth.ValidateScopes(scopes); err != nil {
+		respondWithOAuthError(w, 400, "invalid_scope", err.Error())
+		return database.OauthClient{}, nil, false
+	}
+	return client, slices.Compact(slices.Sorted(slices.Values(scopes))), true
+}
+
+// oauthAuthorizeGetHandler describes an authorization request so the frontend
+// can ask the logged-in user for consent. consent_required is false when the
+// user already allowed the client everything it asks for.
+func (cfg *apiConfig) oauthAuthorizeGetHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	query := r.URL.Query()
+	req := authorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+	client, scopes, ok := cfg.checkAuthorizeRequest(w, r, req)
+	if !ok {
+		return
+	}
+
+	consentRequired := true
+	consent, err := cfg.dbq.GetOAuthConsent(context.Background(), database.GetOAuthConsentParams{UserID: userID, ClientID: client.ID})
+	if err == nil {
+		consentRequired = slices.ContainsFunc(scopes, func(scope string) bool { return !slices.Contains(consent.Scopes, scope) })
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		handleError(w, r, err)
+		return
+	}
+
+	type returnVals struct {
+		ClientID        uuid.UUID `json:"client_id"`
+		ClientName      string    `json:"client_name"`
+		RedirectURI     string    `json:"redirect_uri"`
+		Scopes          []string  `json:"scopes"`
+		ConsentRequired bool      `json:"consent_required"`
+	}
+	respondWithJSON(w, 200, returnVals{ClientID: client.ID, ClientName: client.Name, RedirectURI: req.RedirectURI, Scopes: scopes, ConsentRequired: consentRequired})
+}
+
+// billingGetHandler shows the caller's plan and their invoices from ZingPay.
+// Users who never subscribed are on the free plan.
+func (cfg *apiConfig) billingGetHandler(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireBilling(w) {
+		return
+	}
+	caller := principalFrom(r)
+
+	type returnVals struct {
+		Plan             string            `json:"plan"`
+		Status           string            `json:"status,omitempty"`
+		IsPremium        bool              `json:"is_premium"`
+		CurrentPeriodEnd *time.Time        `json:"current_period_end,omitempty"`
+		GracePeriodEnd   *time.Time        `json:"grace_period_end,omitempty"`
+		Invoices         []zingpay.Invoice `json:"invoices"`
+	}
+	respBody := returnVals{Plan: "free", IsPremium: caller.IsPremium}
+
+	subscription, err := cfg.dbq.GetSubscriptionByUser(context.Background(), caller.UserID)
+	if err == nil && subscription.Status != "expired" {
+		respBody.Plan = subscription.Plan
+		respBody.Status = subscription.Status
+		respBody.CurrentPeriodEnd = &subscription.CurrentPeriodEnd
+		if subscription.GracePeriodEnd.Valid {
+			respBody.GracePeriodEnd = &subscription.GracePeriodEnd.Time
+		}
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		handleError(w, r, err)
+		return
+	}
+
+	respBody.Invoices, err = cfg.zingpay.ListInvoices(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithZingPayError(w, err)
+		return
+	}
+	if respBody.Invoices == nil {
+		respBody.Invoices = []zingpay.Invoice{}
+	}
+	respondWithJSON(w, 200, respBody)
+}
+
+// webhookEventResponse is how admins see an event in the webhook inbox.
+type webhookEventResponse struct {
+	ID            string          `json:"id"`
+	Event         string          `json:"event"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	LastError     *string         `json:"last_error"`
+	ReceivedAt    time.Time       `json:"received_at"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	ProcessedAt   *time.Time      `json:"processed_at,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+func newWebhookEventResponse(event database.WebhookEvent) webhookEventResponse {
+	resp := webhookEventResponse{ID: event.ID, Event: event.EventType, Status: event.Status, Attempts: event.Attempts, ReceivedAt: event.ReceivedAt}
+	if event.LastError.Valid {
+		resp.LastError = &event.LastError.String
+	}
+	if event.Status == "pending" {
+		resp.NextAttemptAt = &event.NextAttemptAt
+	}
+	if event.ProcessedAt.Valid {
+		resp.ProcessedAt = &event.ProcessedAt.Time
+	}
+	// Events received before the inbox existed weren't kept.
+	if event.Payload != "" {
+		resp.Payload = json.RawMessage(event.Payload)
+	}
+	return resp
+}
+
+// webhookEventsGetHandler lists the newest events of the webhook inbox with
+// the given status, dead letters by default.
+func (cfg *apiConfig) webhookEventsGetHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "dead"
+	}
+	if !slices.Contains([]string{"pending", "processed", "dead"}, status) {
+		respondWithError(w, 400, "status must be one of pending, processed or dead")
+		return
+	}
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			respondWithError(w, 400, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	events, err := cfg.dbq.ListWebhookEventsByStatus(context.Background(), database.ListWebhookEventsByStatusParams{Status: status, Limit: int32(limit)})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	respBody := []webhookEventResponse{}
+	for _, event := range events {
+		respBody = append(respBody, newWebhookEventResponse(event))
+	}
+	respondWithJSON(w, 200, respBody)
+}
+
+// userResponse is what anyone may see of a user. ViewerFollows is only set
+// for authenticated callers.
+type userResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	Handle         string     `json:"handle"`
+	DisplayName    string     `json:"display_name"`
+	Bio            string     `json:"bio"`
+	Website        string     `json:"website"`
+	Location       string     `json:"location"`
+	CreatedAt      time.Time  `json:"created_at"`
+	FollowerCount  int32      `json:"follower_count"`
+	FollowingCount int32      `json:"following_count"`
+	FollowedAt     *time.Time `json:"followed_at,omitempty"`
+	LikedAt        *time.Time `json:"liked_at,omitempty"`
+	ViewerFollows  *bool      `json:"viewer_follows,omitempty"`
+}
+
+func newUserResponse(user database.User) userResponse {
+	return userResponse{
+		ID:             user.ID,
+		Handle:         user.Handle,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		Website:        user.Website,
+		Location:       user.Location,
+		CreatedAt:      user.CreatedAt,
+		FollowerCount:  user.FollowerCount,
+		FollowingCount: user.FollowingCount,
+	}
+}
+
+// userGetHandler shows the public profile of the user with the handle in the
+// path, in any case.
+func (cfg *apiConfig) userGetHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.dbq.GetUserByHandle(context.Background(), r.PathValue("handle"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+	respBody := newUserResponse(user)
+
+	if caller := principalFrom(r); caller != nil && caller.UserID != user.ID {
+		viewerFollows, err := cfg.dbq.IsFollowing(context.Background(), database.IsFollowingParams{FollowerID: caller.UserID, FolloweeID: user.ID})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		respBody.ViewerFollows = &viewerFollows
+	}
+	respondWithJSON(w, 200, respBody)
+}
+
+// followsGetHandler lists the followers of the user in the path, or who they
+// follow, most recent follows first.
+func (cfg *apiConfig) followsGetHandler(following bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := uuid.Parse(r.PathValue("userID"))
+		if err != nil {
+			handleErrorNotFound(w)
+			return
+		}
+		page, ok := parsePageRequest(w, r)
+		if !ok {
+			return
+		}
+		if _, err := cfg.dbq.GetUserByID(context.Background(), userID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				handleErrorNotFound(w)
+				return
+			}
+			handleError(w, r, err)
+			return
+		}
+
+		caller := principalFrom(r)
+		params := database.ListFollowersParams{UserID: userID, RowLimit: int32(page.Limit + 1)}
+		if caller != nil {
+			params.ViewerID = uuid.NullUUID{UUID: caller.UserID, Valid: true}
+		}
+		if page.After != nil {
+			params.AfterCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+			params.AfterID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+		}
+
+		var users []database.ListFollowersRow
+		if following {
+			var rows []database.ListFollowingRow
+			rows, err = cfg.dbq.ListFollowing(context.Background(), database.ListFollowingParams(params))
+			for _, row := range rows {
+				users = append(users, database.ListFollowersRow(row))
+			}
+		} else {
+			users, err = cfg.dbq.ListFollowers(context.Background(), params)
+		}
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		users, nextCursor := nextPageCursor(users, page.Limit, func(u database.ListFollowersRow) pageCursor {
+			return pageCursor{CreatedAt: u.FollowedAt, ID: u.ID}
+		})
+
+		type returnVals struct {
+			Users      []userResponse `json:"users"`
+			NextCursor *string        `json:"next_cursor"`
+		}
+		respBody := returnVals{Users: make([]userResponse, len(users)), NextCursor: nextCursor}
+		for i, user := range users {
+			respBody.Users[i] = userResponse{
+				ID:             user.ID,
+				Handle:         user.Handle,
+				DisplayName:    user.DisplayName,
+				Bio:            user.Bio,
+				Website:        user.Website,
+				Location:       user.Location,
+				CreatedAt:      user.CreatedAt,
+				FollowerCount:  user.FollowerCount,
+				FollowingCount: user.FollowingCount,
+				FollowedAt:     &user.FollowedAt,
+			}
+			if caller != nil {
+				respBody.Users[i].ViewerFollows = &user.ViewerFollows
+			}
+		}
+		respondWithJSON(w, 200, respBody)
+	}
+}
+
+// defaultTimelineFanoutThreshold is the follower count from which an author's
+// zingers are no longer copied into every follower's home timeline.
+const defaultTimelineFanoutThreshold = 10000
+
+// homeTimelineGetHandler lists zingers of the caller and everyone they
+// follow, newest first. Most zingers were copied into the caller's timeline
+// when they were posted; those whose authors had at least
+// timeline_fanout_threshold followers at the time are looked up now.
+func (cfg *apiConfig) homeTimelineGetHandler(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	params := database.GetHomeTimelineParams{ViewerID: principalFrom(r).UserID, RowLimit: int32(page.Limit + 1)}
+	if page.After != nil {
+		params.AfterCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	zingers, err := cfg.dbq.GetHomeTimeline(context.Background(), params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	zingers, nextCursor := nextPageCursor(zingers, page.Limit, func(z database.Zinger) pageCursor {
+		return pageCursor{CreatedAt: z.CreatedAt, ID: z.ID}
+	})
+
+	type returnVals struct {
+		Zingers    []zingerResponse `json:"zingers"`
+		NextCursor *string          `json:"next_cursor"`
+	}
+	respBody := returnVals{Zingers: newZingerResponses(zingers), NextCursor: nextCursor}
+	if err := cfg.completeZingerResponses(r, zingerPointers(respBody.Zingers)...); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, respBody)
+}
+
+// zingerLikesGetHandler lists who liked the zinger in the path, most recent
+// likes first.
+func (cfg *apiConfig) zingerLikesGetHandler(w http.ResponseWriter, r *http.Request) {
+	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	zinger, err := cfg.dbq.GetZingerById(context.Background(), zingerID)
+	if err != nil || zinger.DeletedAt.Valid {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+
+	params := database.ListZingerLikesParams{ZingerID: zingerID, RowLimit: int32(page.Limit + 1)}
+	if page.After != nil {
+		params.AfterCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	users, err := cfg.dbq.ListZingerLikes(context.Background(), params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	users, nextCursor := nextPageCursor(users, page.Limit, func(u database.ListZingerLikesRow) pageCursor {
+		return pageCursor{CreatedAt: u.LikedAt, ID: u.ID}
+	})
+
+	type returnVals struct {
+		Users      []userResponse `json:"users"`
+		NextCursor *string        `json:"next_cursor"`
+	}
+	respBody := returnVals{Users: make([]userResponse, len(users)), NextCursor: nextCursor}
+	for i, user := range users {
+		respBody.Users[i] = userResponse{
+			ID:             user.ID,
+			Handle:         user.Handle,
+			DisplayName:    user.DisplayName,
+			Bio:            user.Bio,
+			Website:        user.Website,
+			Location:       user.Location,
+			CreatedAt:      user.CreatedAt,
+			FollowerCount:  user.FollowerCount,
+			FollowingCount: user.FollowingCount,
+			LikedAt:        &user.LikedAt,
+		}
+	}
+	respondWithJSON(w, 200, respBody)
+}
+
+// userLikesGetHandler lists the zingers the user in the path liked, most
+// recent likes first. Zingers deleted since are left out.
+func (cfg *apiConfig) userLikesGetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	if _, err := cfg.dbq.GetUserByID(context.Background(), userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+
+	params := database.ListUserLikesParams{UserID: userID, RowLimit: int32(page.Limit + 1)}
+	if page.After != nil {
+		params.AfterCreatedAt = sql.NullTime{Time: page.After.CreatedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: page.After.ID, Valid: true}
+	}
+	likes, err := cfg.dbq.ListUserLikes(context.Background(), params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	likes, nextCursor := nextPageCursor(likes, page.Limit, func(l database.ListUserLikesRow) pageCursor {
+		return pageCursor{CreatedAt: l.LikedAt, ID: l.Zinger.ID}
+	})
+
+	type returnVals struct {
+		Zingers    []zingerResponse `json:"zingers"`
+		NextCursor *string          `json:"next_cursor"`
+	}
+	respBody := returnVals{Zingers: make([]zingerResponse, len(likes)), NextCursor: nextCursor}
+	for i, like := range likes {
+		respBody.Zingers[i] = newZingerResponse(like.Zinger)
+		respBody.Zingers[i].LikedAt = &like.LikedAt
+	}
+	if err := cfg.completeZingerResponses(r, zingerPointers(respBody.Zingers)...); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	respondWithJSON(w, 200, respBody)
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHasher(t *testing.T) {
+	params := Argon2Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	hasher := NewPasswordHasher(params)
+
+	t.Run("Argon2id round trip", func(t *testing.T) {
+		hash, err := hasher.Hash("hunter22")
+		require.NoError(t, err)
+		assert.Contains(t, hash, "$argon2id$v=19$m=8192,t=1,p=1$")
+
+		needsRehash, err := hasher.Check(hash, "hunter22")
+		assert.NoError(t, err)
+		assert.False(t, needsRehash)
+
+		_, err = hasher.Check(hash, "hunter23")
+		assert.ErrorIs(t, err, ErrPasswordMismatch)
+	})
+
+	t.Run("Salts differ", func(t *testing.T) {
+		first, err := hasher.Hash("hunter22")
+		require.NoError(t, err)
+		second, err := hasher.Hash("hunter22")
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("Outdated argon2id parameters", func(t *testing.T) {
+		hash, err := hasher.Hash("hunter22")
+		require.NoError(t, err)
+
+		stronger := params
+		stronger.Iterations = 2
+		needsRehash, err := NewPasswordHasher(stronger).Check(hash, "hunter22")
+		assert.NoError(t, err)
+		assert.True(t, needsRehash)
+	})
+
+	t.Run("Legacy bcrypt", func(t *testing.T) {
+		legacy, err := bcrypt.GenerateFromPassword([]byte("hunter22"), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		needsRehash, err := hasher.Check(string(legacy), "hunter22")
+		assert.NoError(t, err)
+		assert.True(t, needsRehash)
+
+		_, err = hasher.Check(string(legacy), "hunter23")
+		assert.ErrorIs(t, err, ErrPasswordMismatch)
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		_, err := hasher.Check("unset", "unset")
+		assert.ErrorIs(t, err, ErrUnknownHashFormat)
+
+		_, err = hasher.Check("$argon2id$v=19$garbage", "hunter22")
+		assert.ErrorIs(t, err, ErrUnknownHashFormat)
+	})
+}
@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// SigningKey is one entry of a KeySet. Keys without a private half can only
+// verify tokens, which is how retired keys are kept around during rotation.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// CanSign reports whether the key holds the private half.
+func (k *SigningKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// KeySet signs access tokens with its active key and accepts tokens signed by
+// any key it holds. Tokens carry the key ID in their "kid" header.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewHMACKeySet is the HS256 fallback: a single shared secret that both signs
+// and verifies, with no key ID.
+func NewHMACKeySet(secret string) *KeySet {
+	key := NewHMACKey("", secret)
+	return &KeySet{active: key, keys: map[string]*SigningKey{key.ID: key}}
+}
+
+// NewKeySet builds a key set that signs with the key called activeID.
+func NewKeySet(keys []*SigningKey, activeID string) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey, len(keys))}
+	for _, key := range keys {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+	active, ok := ks.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeID)
+	}
+	if !active.CanSign() {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+// LoadKeysFromDir reads every *.pem file in dir as a key named after the file.
+// Private keys can sign and verify, public keys only verify.
+func LoadKeysFromDir(dir string) ([]*SigningKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		dat, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePEMKey(strings.TrimSuffix(filepath.Base(path), ".pem"), dat)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// NewHMACKey returns an HS256 key that signs and verifies.
+func NewHMACKey(id, secret string) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+}
+
+// NewHMACVerifyKey returns an HS256 key that only verifies. It is added to an
+// asymmetric key set to keep accepting tokens issued before the switch, and
+// can't become the key new tokens are signed with.
+func NewHMACVerifyKey(id, secret string) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, verifyKey: []byte(secret)}
+}
+
+// GenerateEd25519Key creates a fresh EdDSA signing key.
+func GenerateEd25519Key(id string) (*SigningKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, signKey: priv, verifyKey: pub}, nil
+}
+
+// ParsePEMKey accepts Ed25519 or RSA keys, private (PKCS#8 or PKCS#1) or
+// public (PKIX).
+func ParsePEMKey(id string, pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, signKey: k, verifyKey: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: k}, nil
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, verifyKey: k}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", parsed)
+}
+
+// Claims are the JWT claims ZingZing issues. Purpose is empty for access
+// tokens and names the single use of any other kind of token. Access tokens
+// from a first-party login carry the user's role, those issued to an OAuth
+// client its ID and the granted scopes instead.
+type Claims struct {
+	jwt.RegisteredClaims
+	Purpose  string `json:"purpose,omitempty"`
+	Role     string `json:"role,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// PurposeTwoFactorChallenge marks the token handed out between a correct
+// password and a correct second factor.
+const PurposeTwoFactorChallenge = "2fa_challenge"
+
+// AccessToken is what a validated access token says about its bearer.
+// ClientID is uuid.Nil for tokens from a first-party login, which are not
+// limited by scopes. Tokens issued to OAuth clients always have RoleUser.
+type AccessToken struct {
+	UserID   uuid.UUID
+	Role     string
+	ClientID uuid.UUID
+	Scopes   []string
+}
+
+// MakeJWT issues an access token for userID signed with the active key. The
+// token grants no role beyond RoleUser.
+func (ks *KeySet) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return ks.sign(userID, Claims{}, expiresIn)
+}
+
+// MakeJWTWithRole is MakeJWT for a user whose role is recorded in the token.
+func (ks *KeySet) MakeJWTWithRole(userID uuid.UUID, role string, expiresIn time.Duration) (string, error) {
+	return ks.sign(userID, Claims{Role: role}, expiresIn)
+}
+
+// MakeOAuthJWT issues an access token that lets clientID act for userID
+// within scopes.
+func (ks *KeySet) MakeOAuthJWT(userID, clientID uuid.UUID, scopes []string, expiresIn time.Duration) (string, error) {
+	return ks.sign(userID, Claims{ClientID: clientID.String(), Scope: strings.Join(scopes, " ")}, expiresIn)
+}
+
+// ValidateJWT checks the token against the key named by its "kid" header and
+// returns the user ID it was issued for. Tokens issued to OAuth clients are
+// rejected, use ParseAccessToken where those are welcome.
+func (ks *KeySet) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	token, err := ks.ParseAccessToken(tokenString)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if token.ClientID != uuid.Nil {
+		return uuid.Nil, ErrInvalidJWT
+	}
+	return token.UserID, nil
+}
+
+// ParseAccessToken validates any access token, first-party or OAuth.
+func (ks *KeySet) ParseAccessToken(tokenString string) (AccessToken, error) {
+	userID, claims, err := ks.validate(tokenString, "")
+	if err != nil {
+		return AccessToken{}, err
+	}
+	token := AccessToken{UserID: userID, Role: RoleUser}
+	if claims.ClientID == "" && claims.Role != "" {
+		token.Role = claims.Role
+	}
+	if claims.ClientID != "" {
+		token.ClientID, err = uuid.Parse(claims.ClientID)
+		if err != nil {
+			return AccessToken{}, ErrInvalidJWT
+		}
+		token.Scopes = strings.Fields(claims.Scope)
+	}
+	return token, nil
+}
+
+// MakeChallengeJWT issues a short-lived token proving that userID got past the
+// password step of a two-factor login. It is not accepted as an access token.
+func (ks *KeySet) MakeChallengeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return ks.sign(userID, Claims{Purpose: PurposeTwoFactorChallenge}, expiresIn)
+}
+
+// ValidateChallengeJWT is ValidateJWT for tokens from MakeChallengeJWT.
+func (ks *KeySet) ValidateChallengeJWT(tokenString string) (uuid.UUID, error) {
+	userID, _, err := ks.validate(tokenString, PurposeTwoFactorChallenge)
+	return userID, err
+}
+
+func (ks *KeySet) sign(userID uuid.UUID, claims Claims, expiresIn time.Duration) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{Issuer: "zingery", Subject: userID.String(), IssuedAt: jwt.NewNumericDate(time.Now()), ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn))}
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	if ks.active.ID != "" {
+		token.Header["kid"] = ks.active.ID
+	}
+	return token.SignedString(ks.active.signKey)
+}
+
+func (ks *KeySet) validate(tokenString, purpose string) (uuid.UUID, *Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, ks.keyFunc)
+	if err != nil || !token.Valid {
+		return uuid.Nil, nil, ErrInvalidJWT
+	}
+	claims, ok := token.Claims.(*Claims)
+	if !ok || claims.Purpose != purpose {
+		return uuid.Nil, nil, ErrInvalidJWT
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("could not parse user ID: %w", err)
+	}
+	return userID, claims, nil
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrInvalidJWT
+	}
+	// The algorithm comes from the key, never from the token, otherwise a
+	// public key could be replayed as an HMAC secret.
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidJWT
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is a public key in RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public half of every asymmetric key in the set. Shared
+// secrets are never published.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		switch pub := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{Kty: "OKP", Kid: key.ID, Use: "sig", Alg: key.Method.Alg(), Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub)})
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{Kty: "RSA", Kid: key.ID, Use: "sig", Alg: key.Method.Alg(), N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes()), E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
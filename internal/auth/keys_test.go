@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySet(t *testing.T) {
+	userID := uuid.New()
+
+	oldKey, err := GenerateEd25519Key("2024-01")
+	require.NoError(t, err)
+	newKey, err := GenerateEd25519Key("2024-06")
+	require.NoError(t, err)
+
+	t.Run("EdDSA round trip", func(t *testing.T) {
+		ks, err := NewKeySet([]*SigningKey{oldKey}, "2024-01")
+		require.NoError(t, err)
+
+		token, err := ks.MakeJWT(userID, time.Hour)
+		require.NoError(t, err)
+
+		extractedID, err := ks.ValidateJWT(token)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, extractedID)
+	})
+
+	t.Run("Rotation keeps old tokens valid", func(t *testing.T) {
+		before, err := NewKeySet([]*SigningKey{oldKey}, "2024-01")
+		require.NoError(t, err)
+		token, err := before.MakeJWT(userID, time.Hour)
+		require.NoError(t, err)
+
+		after, err := NewKeySet([]*SigningKey{oldKey, newKey}, "2024-06")
+		require.NoError(t, err)
+		extractedID, err := after.ValidateJWT(token)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, extractedID)
+	})
+
+	t.Run("Unknown key ID", func(t *testing.T) {
+		signer, err := NewKeySet([]*SigningKey{newKey}, "2024-06")
+		require.NoError(t, err)
+		token, err := signer.MakeJWT(userID, time.Hour)
+		require.NoError(t, err)
+
+		verifier, err := NewKeySet([]*SigningKey{oldKey}, "2024-01")
+		require.NoError(t, err)
+		_, err = verifier.ValidateJWT(token)
+		assert.ErrorIs(t, err, ErrInvalidJWT)
+	})
+
+	t.Run("HS256 rejected without shared secret", func(t *testing.T) {
+		token, err := MakeJWT(userID, "secret", time.Hour)
+		require.NoError(t, err)
+
+		ks, err := NewKeySet([]*SigningKey{oldKey}, "2024-01")
+		require.NoError(t, err)
+		_, err = ks.ValidateJWT(token)
+		assert.ErrorIs(t, err, ErrInvalidJWT)
+
+		ks, err = NewKeySet([]*SigningKey{oldKey, NewHMACVerifyKey("", "secret")}, "2024-01")
+		require.NoError(t, err)
+		extractedID, err := ks.ValidateJWT(token)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, extractedID)
+	})
+
+	t.Run("Challenge tokens are not access tokens", func(t *testing.T) {
+		ks, err := NewKeySet([]*SigningKey{oldKey}, "2024-01")
+		require.NoError(t, err)
+
+		challenge, err := ks.MakeChallengeJWT(userID, time.Minute)
+		require.NoError(t, err)
+		_, err = ks.ValidateJWT(challenge)
+		assert.ErrorIs(t, err, ErrInvalidJWT)
+		extractedID, err := ks.ValidateChallengeJWT(challenge)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, extractedID)
+
+		access, err := ks.MakeJWT(userID, time.Minute)
+		require.NoError(t, err)
+		_, err = ks.ValidateChallengeJWT(access)
+		assert.ErrorIs(t, err, ErrInvalidJWT)
+	})
+
+	t.Run("Active key must be private", func(t *testing.T) {
+		_, err := NewKeySet([]*SigningKey{{ID: "pub", Method: oldKey.Method, verifyKey: oldKey.verifyKey}}, "pub")
+		assert.Error(t, err)
+		_, err = NewKeySet([]*SigningKey{oldKey, NewHMACVerifyKey("", "secret")}, "")
+		assert.Error(t, err, "the shared secret never signs next to asymmetric keys")
+	})
+
+	t.Run("JWKS publishes only public keys", func(t *testing.T) {
+		ks, err := NewKeySet([]*SigningKey{oldKey, newKey, NewHMACVerifyKey("", "secret")}, "2024-06")
+		require.NoError(t, err)
+
+		jwks := ks.JWKS()
+		require.Len(t, jwks.Keys, 2)
+		assert.Equal(t, "2024-01", jwks.Keys[0].Kid)
+		assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+		assert.Equal(t, "EdDSA", jwks.Keys[0].Alg)
+		assert.NotEmpty(t, jwks.Keys[0].X)
+	})
+}
+
+func TestLoadKeysFromDir(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privDER, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "current.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600))
+
+	retired, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(&retired.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "retired.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0600))
+
+	keys, err := LoadKeysFromDir(dir)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	_, err = NewKeySet(keys, "retired")
+	assert.Error(t, err)
+
+	ks, err := NewKeySet(keys, "current")
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	token, err := ks.MakeJWT(userID, time.Hour)
+	require.NoError(t, err)
+	extractedID, err := ks.ValidateJWT(token)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, extractedID)
+
+	jwks := ks.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "RS256", jwks.Keys[0].Alg)
+	assert.Equal(t, "AQAB", jwks.Keys[0].E)
+}
@@ -0,0 +1,33 @@
+package auth
+
+import "time"
+
+// LockoutPolicy decides how long logins are refused after repeated failures.
+// The first Threshold-1 failures are free; the failure that reaches the
+// threshold locks for BaseDelay, and every further one doubles the lock up to
+// MaxDelay. Failures are forgotten after Window without a new one.
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// Delay returns how long to lock after the given number of consecutive
+// failures.
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutPolicy(t *testing.T) {
+	p := LockoutPolicy{Threshold: 3, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute, Window: time.Hour}
+
+	assert.Equal(t, time.Duration(0), p.Delay(1))
+	assert.Equal(t, time.Duration(0), p.Delay(2))
+	assert.Equal(t, 30*time.Second, p.Delay(3))
+	assert.Equal(t, time.Minute, p.Delay(4))
+	assert.Equal(t, 2*time.Minute, p.Delay(5))
+	assert.Equal(t, 4*time.Minute, p.Delay(6))
+	assert.Equal(t, 5*time.Minute, p.Delay(7))
+	assert.Equal(t, 5*time.Minute, p.Delay(1000))
+
+	disabled := LockoutPolicy{}
+	assert.Equal(t, time.Duration(0), disabled.Delay(1000))
+}
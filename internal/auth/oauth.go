@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// PKCEMethodS256 is the only PKCE challenge method accepted. The "plain"
+// method would leak the verifier along with the authorization request.
+const PKCEMethodS256 = "S256"
+
+// PKCEChallenge derives the S256 code challenge for verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPKCE reports whether verifier is well-formed (RFC 7636, section 4.1)
+// and matches the S256 challenge sent with the authorization request.
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		if !isUnreserved(c) {
+			return false
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(PKCEChallenge(verifier)), []byte(challenge)) == 1
+}
+
+func isUnreserved(c rune) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)
+}
+
+// ValidateRedirectURI checks a redirect URI an OAuth client registers. It has
+// to be an absolute URL without a fragment, and plain http is only allowed for
+// loopback addresses used by native apps.
+func ValidateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" || u.Fragment != "" {
+		return errors.New("redirect URI must be an absolute URL without a fragment")
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || ip != nil && ip.IsLoopback() {
+			return nil
+		}
+	}
+	return errors.New("redirect URI must use https")
+}
+
+// clientSecretPrefix marks OAuth client secrets for secret scanners.
+const clientSecretPrefix = "zzs_"
+
+// MakeClientSecret returns a new random OAuth client secret.
+func MakeClientSecret() (string, error) {
+	secret, err := MakeToken()
+	if err != nil {
+		return "", err
+	}
+	return clientSecretPrefix + secret, nil
+}
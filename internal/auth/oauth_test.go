@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// Example from RFC 7636, appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	assert.Equal(t, challenge, PKCEChallenge(verifier))
+	assert.True(t, VerifyPKCE(verifier, challenge))
+
+	assert.False(t, VerifyPKCE(verifier[:42], PKCEChallenge(verifier[:42])), "too short")
+	assert.False(t, VerifyPKCE(strings.Repeat("a", 129), PKCEChallenge(strings.Repeat("a", 129))), "too long")
+	bad := strings.Repeat("a", 42) + "+"
+	assert.False(t, VerifyPKCE(bad, PKCEChallenge(bad)), "reserved character")
+	assert.False(t, VerifyPKCE(strings.Repeat("b", 43), challenge))
+}
+
+func TestValidateRedirectURI(t *testing.T) {
+	for _, uri := range []string{"https://app.example.com/callback", "http://localhost:3000/cb", "http://127.0.0.1:8765/"} {
+		assert.NoError(t, ValidateRedirectURI(uri), uri)
+	}
+	for _, uri := range []string{"http://app.example.com/callback", "https://app.example.com/cb#frag", "/callback", "javascript:alert(1)", "::"} {
+		assert.Error(t, ValidateRedirectURI(uri), uri)
+	}
+}
+
+func TestOAuthJWT(t *testing.T) {
+	ks := NewHMACKeySet("secret")
+	userID, clientID := uuid.New(), uuid.New()
+
+	token, err := ks.MakeOAuthJWT(userID, clientID, []string{ScopeZingersRead, ScopeZingersWrite}, time.Hour)
+	require.NoError(t, err)
+
+	parsed, err := ks.ParseAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, AccessToken{UserID: userID, Role: RoleUser, ClientID: clientID, Scopes: []string{ScopeZingersRead, ScopeZingersWrite}}, parsed)
+
+	_, err = ks.ValidateJWT(token)
+	assert.ErrorIs(t, err, ErrInvalidJWT, "client tokens are not first-party tokens")
+
+	firstParty, err := ks.MakeJWT(userID, time.Hour)
+	require.NoError(t, err)
+	parsed, err = ks.ParseAccessToken(firstParty)
+	require.NoError(t, err)
+	assert.Equal(t, AccessToken{UserID: userID, Role: RoleUser}, parsed)
+}
@@ -2,68 +2,36 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hash), nil
+	return defaultHasher.Hash(password)
 }
 
 
 func CheckPasswordHash(hash, password string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	_, err := defaultHasher.Check(hash, password)
 	return err
 }
 
 
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Issuer: "zingery", Subject: userID.String(), IssuedAt: jwt.NewNumericDate(time.Now()), ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn))})
-	tokenString, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		return "", err
-	}
-	return tokenString, nil
+	return NewHMACKeySet(tokenSecret).MakeJWT(userID, expiresIn)
 }
 
 
 var ErrInvalidJWT = errors.New("invalid JWT token")
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidJWT
-		}
-		
-		return []byte(tokenSecret), nil
-	})
-
-	if err!= nil || !token.Valid {
-		return uuid.Nil, ErrInvalidJWT
-	}
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok {
-		return uuid.Nil, ErrInvalidJWT
-	}
-
-	userID, err := uuid.Parse(claims.Subject)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("could not parse user ID: %w", err)
-	}
-
-	return userID, nil
+	return NewHMACKeySet(tokenSecret).ValidateJWT(tokenString)
 }
 
 
@@ -77,6 +45,12 @@ func GetBearerToken(headers http.Header) (string, error) {
 
 
 func MakeRefreshToken() (string, error) {
+	return MakeToken()
+}
+
+
+// MakeToken returns 32 random bytes, hex encoded, for use as a bearer secret.
+func MakeToken() (string, error) {
 	bytes := make([]byte, 32)
 	_, err := rand.Read(bytes)
 	if err != nil {
@@ -87,6 +61,14 @@ func MakeRefreshToken() (string, error) {
 }
 
 
+// HashToken returns the digest a single-use token is stored under, so that
+// reading the database doesn't hand out working tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
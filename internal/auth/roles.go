@@ -0,0 +1,25 @@
+package auth
+
+import "slices"
+
+// Roles a user can have, from least to most privileged. Every role can do
+// what the ones before it can.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	return slices.Contains(roles, role)
+}
+
+// HasRole reports whether role grants at least the privileges of required.
+// Unknown roles grant nothing.
+func HasRole(role, required string) bool {
+	have := slices.Index(roles, role)
+	return have >= 0 && have >= slices.Index(roles, required)
+}
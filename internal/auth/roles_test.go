@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasRole(t *testing.T) {
+	assert.True(t, HasRole(RoleAdmin, RoleAdmin))
+	assert.True(t, HasRole(RoleAdmin, RoleModerator))
+	assert.True(t, HasRole(RoleModerator, RoleUser))
+	assert.False(t, HasRole(RoleModerator, RoleAdmin))
+	assert.False(t, HasRole(RoleUser, RoleModerator))
+	assert.False(t, HasRole("", RoleUser))
+	assert.False(t, HasRole("superuser", RoleUser))
+	assert.False(t, ValidRole("superuser"))
+}
+
+func TestRoleClaim(t *testing.T) {
+	ks := NewHMACKeySet("secret")
+	userID := uuid.New()
+
+	token, err := ks.MakeJWTWithRole(userID, RoleAdmin, time.Hour)
+	require.NoError(t, err)
+	parsed, err := ks.ParseAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, parsed.Role)
+
+	token, err = ks.MakeJWT(userID, time.Hour)
+	require.NoError(t, err)
+	parsed, err = ks.ParseAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, RoleUser, parsed.Role)
+}
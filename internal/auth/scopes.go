@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scopes limit what a delegated credential, such as a personal access token,
+// may do on its owner's behalf. No scope covers the account's email or
+// password: ScopeProfileWrite is about the public profile only.
+const (
+	ScopeZingersRead  = "zingers:read"
+	ScopeZingersWrite = "zingers:write"
+	ScopeProfileWrite = "profile:write"
+	ScopeFollowsRead  = "follows:read"
+	ScopeFollowsWrite = "follows:write"
+)
+
+// AllScopes lists every scope a credential can be granted.
+var AllScopes = []string{ScopeZingersRead, ScopeZingersWrite, ScopeProfileWrite, ScopeFollowsRead, ScopeFollowsWrite}
+
+// ValidateScopes rejects empty lists and unknown scope names.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(AllScopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// personalAccessTokenPrefix tells personal access tokens apart from JWTs at a
+// glance, and makes them easy to spot for secret scanners.
+const personalAccessTokenPrefix = "zzp_"
+
+// MakePersonalAccessToken returns a new random personal access token.
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeToken()
+	if err != nil {
+		return "", err
+	}
+	return personalAccessTokenPrefix + token, nil
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal access
+// token rather than a JWT.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
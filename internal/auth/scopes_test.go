@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateScopes(t *testing.T) {
+	assert.NoError(t, ValidateScopes([]string{ScopeZingersRead, ScopeZingersWrite}))
+	assert.Error(t, ValidateScopes(nil))
+	assert.Error(t, ValidateScopes([]string{ScopeZingersRead, "admin"}))
+}
+
+func TestPersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	require.NoError(t, err)
+	assert.True(t, IsPersonalAccessToken(token))
+
+	jwt, err := MakeJWT([16]byte{1}, "secret", 0)
+	require.NoError(t, err)
+	assert.False(t, IsPersonalAccessToken(jwt))
+}
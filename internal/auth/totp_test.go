@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B test secret ("12345678901234567890"), SHA1 vectors
+	// truncated to 6 digits.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	t.Run("RFC 6238 vectors", func(t *testing.T) {
+		vectors := map[int64]string{
+			59:         "287082",
+			1111111109: "081804",
+			1111111111: "050471",
+			1234567890: "005924",
+			2000000000: "279037",
+		}
+		for unix, want := range vectors {
+			code, err := TOTPCode(secret, time.Unix(unix, 0))
+			require.NoError(t, err)
+			assert.Equal(t, want, code, "time %d", unix)
+		}
+	})
+
+	t.Run("Skew and replay", func(t *testing.T) {
+		now := time.Unix(1111111111, 0)
+		previous, err := TOTPCode(secret, now.Add(-30*time.Second))
+		require.NoError(t, err)
+
+		step, ok := ValidateTOTP(secret, previous, now, 0)
+		assert.True(t, ok)
+		assert.Equal(t, now.Unix()/30-1, step)
+
+		_, ok = ValidateTOTP(secret, previous, now, step)
+		assert.False(t, ok)
+
+		stale, err := TOTPCode(secret, now.Add(-2*time.Minute))
+		require.NoError(t, err)
+		_, ok = ValidateTOTP(secret, stale, now, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("Generated secrets work", func(t *testing.T) {
+		generated, err := GenerateTOTPSecret()
+		require.NoError(t, err)
+		code, err := TOTPCode(generated, time.Now())
+		require.NoError(t, err)
+		_, ok := ValidateTOTP(generated, code, time.Now(), 0)
+		assert.True(t, ok)
+	})
+
+	t.Run("URI", func(t *testing.T) {
+		uri := TOTPURI("ZingZing", "ada@example.com", "ABC")
+		assert.Equal(t, "otpauth://totp/ZingZing:ada@example.com?algorithm=SHA1&digits=6&issuer=ZingZing&period=30&secret=ABC", uri)
+	})
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, codes[0])
+	assert.NotEqual(t, codes[0], codes[1])
+
+	assert.Equal(t, NormalizeRecoveryCode(codes[0]), NormalizeRecoveryCode(" "+codes[0][:5]+" "+codes[0][6:]+" "))
+}
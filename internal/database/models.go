@@ -6,33 +6,198 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Zinger struct {
-	ID        uuid.UUID
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	InReplyToID    uuid.NullUUID
+	ConversationID uuid.UUID
+	ReplyCount     int32
+	DeletedAt      sql.NullTime
+	LikeCount      int32
+	RezingOfID     uuid.NullUUID
+	QuoteOfID      uuid.NullUUID
+	RezingCount    int32
+	QuoteCount     int32
+	EditCount      int32
+	FannedOut      bool
+}
+
+type RefreshToken struct {
+	Token            string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	ExpiresAt        time.Time
+	RevokedAt        sql.NullTime
+	FamilyID         uuid.UUID
+	ParentToken      sql.NullString
+	UsedAt           sql.NullTime
+	SessionStartedAt time.Time
+	LastUsedAt       time.Time
+	UserAgent        string
+	IpAddress        string
+	ClientID         uuid.NullUUID
+	Scopes           []string
+}
+
+type User struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	EmailVerifiedAt sql.NullTime
+	TotpSecret      sql.NullString
+	TotpEnabledAt   sql.NullTime
+	TotpLastStep    int64
+	Role            string
+	FollowerCount   int32
+	FollowingCount  int32
+	Handle          string
+	DisplayName     string
+	Bio             string
+	Website         string
+	Location        string
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PasswordResetToken struct {
+	TokenHash string
 	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
 }
 
-type RefreshToken struct {
-	Token     string
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type LoginThrottle struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	CreatedAt    time.Time
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	FamilyID      uuid.UUID
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthConsent struct {
+	UserID    uuid.UUID
+	ClientID  uuid.UUID
+	Scopes    []string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+}
+
+type AdminAuditLog struct {
+	ID           uuid.UUID
+	ActorID      uuid.NullUUID
+	Action       string
+	TargetUserID uuid.NullUUID
+	Details      json.RawMessage
+	IpAddress    string
+	CreatedAt    time.Time
+}
+
+type WebhookEvent struct {
+	ID            string
+	EventType     string
+	ReceivedAt    time.Time
+	Payload       string
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	ProcessedAt   sql.NullTime
+}
+
+type Subscription struct {
+	ID               uuid.UUID
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	GracePeriodEnd   sql.NullTime
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type TimelineEntry struct {
 	UserID    uuid.UUID
-	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	ZingerID  uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
 }
 
-type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsPremium    bool
+type Like struct {
+	UserID    uuid.UUID
+	ZingerID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type ZingerRevision struct {
+	ZingerID  uuid.UUID
+	Revision  int32
+	Body      string
+	CreatedAt time.Time
 }
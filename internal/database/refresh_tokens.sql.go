@@ -11,26 +11,43 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, family_id, parent_token, session_started_at, last_used_at, user_agent, ip_address, client_id, scopes)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11,
+    $12,
+    $13
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, parent_token, used_at, session_started_at, last_used_at, user_agent, ip_address, client_id, scopes
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	ExpiresAt time.Time
+	Token            string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	ExpiresAt        time.Time
+	FamilyID         uuid.UUID
+	ParentToken      sql.NullString
+	SessionStartedAt time.Time
+	LastUsedAt       time.Time
+	UserAgent        string
+	IpAddress        string
+	ClientID         uuid.NullUUID
+	Scopes           []string
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
@@ -40,6 +57,14 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		arg.UpdatedAt,
 		arg.UserID,
 		arg.ExpiresAt,
+		arg.FamilyID,
+		arg.ParentToken,
+		arg.SessionStartedAt,
+		arg.LastUsedAt,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.ClientID,
+		pq.Array(arg.Scopes),
 	)
 	var i RefreshToken
 	err := row.Scan(
@@ -49,12 +74,71 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ParentToken,
+		&i.UsedAt,
+		&i.SessionStartedAt,
+		&i.LastUsedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
+const getActiveSessionsByUser = `-- name: GetActiveSessionsByUser :many
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, parent_token, used_at, session_started_at, last_used_at, user_agent, ip_address, client_id, scopes FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND used_at IS NULL AND expires_at > $2
+ORDER BY last_used_at DESC
+`
+
+type GetActiveSessionsByUserParams struct {
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+func (q *Queries) GetActiveSessionsByUser(ctx context.Context, arg GetActiveSessionsByUserParams) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveSessionsByUser, arg.UserID, arg.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.Token,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.FamilyID,
+			&i.ParentToken,
+			&i.UsedAt,
+			&i.SessionStartedAt,
+			&i.LastUsedAt,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.ClientID,
+			pq.Array(&i.Scopes),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getRefreshTokenByToken = `-- name: GetRefreshTokenByToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens WHERE token = $1
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, parent_token, used_at, session_started_at, last_used_at, user_agent, ip_address, client_id, scopes FROM refresh_tokens WHERE token = $1
 `
 
 func (q *Queries) GetRefreshTokenByToken(ctx context.Context, token string) (RefreshToken, error) {
@@ -67,10 +151,54 @@ func (q *Queries) GetRefreshTokenByToken(ctx context.Context, token string) (Ref
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ParentToken,
+		&i.UsedAt,
+		&i.SessionStartedAt,
+		&i.LastUsedAt,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
+const markRefreshTokenUsed = `-- name: MarkRefreshTokenUsed :execrows
+UPDATE refresh_tokens SET updated_at = $1, used_at = $2
+WHERE token = $3 AND used_at IS NULL AND revoked_at IS NULL
+`
+
+type MarkRefreshTokenUsedParams struct {
+	UpdatedAt time.Time
+	UsedAt    sql.NullTime
+	Token     string
+}
+
+func (q *Queries) MarkRefreshTokenUsed(ctx context.Context, arg MarkRefreshTokenUsedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markRefreshTokenUsed, arg.UpdatedAt, arg.UsedAt, arg.Token)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeAllRefreshTokensForUser = `-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens SET updated_at = $1, revoked_at = $2
+WHERE user_id = $3 AND revoked_at IS NULL
+`
+
+type RevokeAllRefreshTokensForUserParams struct {
+	UpdatedAt time.Time
+	RevokedAt sql.NullTime
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeAllRefreshTokensForUser(ctx context.Context, arg RevokeAllRefreshTokensForUserParams) error {
+	_, err := q.db.ExecContext(ctx, revokeAllRefreshTokensForUser, arg.UpdatedAt, arg.RevokedAt, arg.UserID)
+	return err
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens SET updated_at = $1, revoked_at = $2 WHERE token = $3
 `
@@ -85,3 +213,44 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, arg RevokeRefreshToken
 	_, err := q.db.ExecContext(ctx, revokeRefreshToken, arg.UpdatedAt, arg.RevokedAt, arg.Token)
 	return err
 }
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens SET updated_at = $1, revoked_at = $2
+WHERE family_id = $3 AND revoked_at IS NULL
+`
+
+type RevokeRefreshTokenFamilyParams struct {
+	UpdatedAt time.Time
+	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+}
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, arg RevokeRefreshTokenFamilyParams) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, arg.UpdatedAt, arg.RevokedAt, arg.FamilyID)
+	return err
+}
+
+const revokeSessionForUser = `-- name: RevokeSessionForUser :execrows
+UPDATE refresh_tokens SET updated_at = $1, revoked_at = $2
+WHERE family_id = $3 AND user_id = $4 AND revoked_at IS NULL
+`
+
+type RevokeSessionForUserParams struct {
+	UpdatedAt time.Time
+	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeSessionForUser(ctx context.Context, arg RevokeSessionForUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeSessionForUser,
+		arg.UpdatedAt,
+		arg.RevokedAt,
+		arg.FamilyID,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -7,21 +7,41 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const addUserFollowCounts = `-- name: AddUserFollowCounts :exec
+UPDATE users SET
+    follower_count = follower_count + CASE WHEN id = $1 THEN $2::integer ELSE 0 END,
+    following_count = following_count + CASE WHEN id = $3 THEN $2::integer ELSE 0 END
+WHERE id IN ($1, $3)
+`
+
+type AddUserFollowCountsParams struct {
+	FolloweeID uuid.UUID
+	Delta      int32
+	FollowerID uuid.UUID
+}
+
+func (q *Queries) AddUserFollowCounts(ctx context.Context, arg AddUserFollowCountsParams) error {
+	_, err := q.db.ExecContext(ctx, addUserFollowCounts, arg.FolloweeID, arg.Delta, arg.FollowerID)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_premium
+RETURNING id, created_at, updated_at, email, hashed_password, email_verified_at, totp_secret, totp_enabled_at, totp_last_step, role, follower_count, following_count, handle, display_name, bio, website, location
 `
 
 type CreateUserParams struct {
@@ -30,6 +50,7 @@ type CreateUserParams struct {
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
+	Handle         string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -39,6 +60,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.UpdatedAt,
 		arg.Email,
 		arg.HashedPassword,
+		arg.Handle,
 	)
 	var i User
 	err := row.Scan(
@@ -47,13 +69,78 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		&i.Location,
+	)
+	return i, err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :exec
+UPDATE users SET totp_enabled_at = $1, totp_last_step = $2, updated_at = $3 WHERE id = $4
+`
+
+type EnableUserTOTPParams struct {
+	TotpEnabledAt sql.NullTime
+	TotpLastStep  int64
+	UpdatedAt     time.Time
+	ID            uuid.UUID
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, enableUserTOTP,
+		arg.TotpEnabledAt,
+		arg.TotpLastStep,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
+
+const getPrincipalUser = `-- name: GetPrincipalUser :one
+SELECT users.id, users.role, users.email_verified_at, EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id AND subscriptions.status <> 'expired'
+        AND COALESCE(subscriptions.grace_period_end, subscriptions.current_period_end) > $1
+) AS is_premium
+FROM users WHERE users.id = $2
+`
+
+type GetPrincipalUserParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+type GetPrincipalUserRow struct {
+	ID              uuid.UUID
+	Role            string
+	EmailVerifiedAt sql.NullTime
+	IsPremium       bool
+}
+
+func (q *Queries) GetPrincipalUser(ctx context.Context, arg GetPrincipalUserParams) (GetPrincipalUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getPrincipalUser, arg.Now, arg.ID)
+	var i GetPrincipalUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.Role,
+		&i.EmailVerifiedAt,
 		&i.IsPremium,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_premium FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, email_verified_at, totp_secret, totp_enabled_at, totp_last_step, role, follower_count, following_count, handle, display_name, bio, website, location FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -65,7 +152,76 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsPremium,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		&i.Location,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, email_verified_at, totp_secret, totp_enabled_at, totp_last_step, role, follower_count, following_count, handle, display_name, bio, website, location FROM users WHERE LOWER(handle) = LOWER($1)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, lower)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		&i.Location,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, email_verified_at, totp_secret, totp_enabled_at, totp_last_step, role, follower_count, following_count, handle, display_name, bio, website, location FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		&i.Location,
 	)
 	return i, err
 }
@@ -74,7 +230,7 @@ const getUserByRefreshToken = `-- name: GetUserByRefreshToken :one
 WITH token_user AS (
     SELECT user_id FROM refresh_tokens WHERE token = $1
 )
-SELECT id, created_at, updated_at, email, hashed_password, is_premium FROM users WHERE id = (SELECT user_id FROM token_user)
+SELECT id, created_at, updated_at, email, hashed_password, email_verified_at, totp_secret, totp_enabled_at, totp_last_step, role, follower_count, following_count, handle, display_name, bio, website, location FROM users WHERE id = (SELECT user_id FROM token_user)
 `
 
 func (q *Queries) GetUserByRefreshToken(ctx context.Context, token string) (User, error) {
@@ -86,13 +242,98 @@ func (q *Queries) GetUserByRefreshToken(ctx context.Context, token string) (User
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsPremium,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		&i.Location,
 	)
 	return i, err
 }
 
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :execrows
+UPDATE users SET email_verified_at = $1, updated_at = $2 WHERE id = $3 AND email = $4
+`
+
+type MarkUserEmailVerifiedParams struct {
+	EmailVerifiedAt sql.NullTime
+	UpdatedAt       time.Time
+	ID              uuid.UUID
+	Email           string
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markUserEmailVerified,
+		arg.EmailVerifiedAt,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.Email,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const promoteFirstAdmin = `-- name: PromoteFirstAdmin :execrows
+UPDATE users SET role = 'admin', updated_at = $1
+WHERE id = $2 AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin')
+`
+
+type PromoteFirstAdminParams struct {
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) PromoteFirstAdmin(ctx context.Context, arg PromoteFirstAdminParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, promoteFirstAdmin, arg.UpdatedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserRole = `-- name: SetUserRole :exec
+UPDATE users SET role = $1, updated_at = $2 WHERE id = $3
+`
+
+type SetUserRoleParams struct {
+	Role      string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) error {
+	_, err := q.db.ExecContext(ctx, setUserRole, arg.Role, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users SET totp_secret = $1, totp_enabled_at = NULL, updated_at = $2 WHERE id = $3
+`
+
+type SetUserTOTPSecretParams struct {
+	TotpSecret sql.NullString
+	UpdatedAt  time.Time
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTOTPSecret, arg.TotpSecret, arg.UpdatedAt, arg.ID)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :exec
-UPDATE users SET email = $1, hashed_password = $2, updated_at = $3 WHERE id = $4
+UPDATE users SET email = $1, hashed_password = $2, updated_at = $3,
+    email_verified_at = CASE WHEN email = $1 THEN email_verified_at ELSE NULL END
+WHERE id = $4
 `
 
 type UpdateUserParams struct {
@@ -112,11 +353,111 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
 	return err
 }
 
-const upgradeToZingZingRed = `-- name: UpgradeToPremium :exec
-UPDATE users SET is_premium = TRUE WHERE id = $1
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET hashed_password = $1, updated_at = $2 WHERE id = $3
 `
 
-func (q *Queries) UpgradeToPremium(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeToZingZingRed, id)
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	UpdatedAt      time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.UpdatedAt, arg.ID)
 	return err
 }
+
+const updateUserPasswordIfHash = `-- name: UpdateUserPasswordIfHash :exec
+UPDATE users SET hashed_password = $1, updated_at = $2
+WHERE id = $3 AND hashed_password = $4
+`
+
+type UpdateUserPasswordIfHashParams struct {
+	NewHash   string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+	OldHash   string
+}
+
+func (q *Queries) UpdateUserPasswordIfHash(ctx context.Context, arg UpdateUserPasswordIfHashParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPasswordIfHash,
+		arg.NewHash,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.OldHash,
+	)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users SET
+    handle = COALESCE($1, handle),
+    display_name = COALESCE($2, display_name),
+    bio = COALESCE($3, bio),
+    website = COALESCE($4, website),
+    location = COALESCE($5, location),
+    updated_at = $6
+WHERE id = $7
+RETURNING id, created_at, updated_at, email, hashed_password, email_verified_at, totp_secret, totp_enabled_at, totp_last_step, role, follower_count, following_count, handle, display_name, bio, website, location
+`
+
+type UpdateUserProfileParams struct {
+	Handle      sql.NullString
+	DisplayName sql.NullString
+	Bio         sql.NullString
+	Website     sql.NullString
+	Location    sql.NullString
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Website,
+		arg.Location,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.Role,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Website,
+		&i.Location,
+	)
+	return i, err
+}
+
+const updateUserTOTPLastStep = `-- name: UpdateUserTOTPLastStep :execrows
+UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1
+`
+
+type UpdateUserTOTPLastStepParams struct {
+	TotpLastStep int64
+	ID           uuid.UUID
+}
+
+func (q *Queries) UpdateUserTOTPLastStep(ctx context.Context, arg UpdateUserTOTPLastStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserTOTPLastStep, arg.TotpLastStep, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -7,29 +7,88 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const createRezing = `-- name: CreateRezing :one
+INSERT INTO zingers (id, created_at, updated_at, body, user_id, conversation_id, rezing_of_id)
+VALUES (
+    $1,
+    $2,
+    $2,
+    '',
+    $3,
+    $1,
+    $4
+)
+ON CONFLICT (user_id, rezing_of_id) WHERE rezing_of_id IS NOT NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out
+`
+
+type CreateRezingParams struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	RezingOfID uuid.NullUUID
+}
+
+func (q *Queries) CreateRezing(ctx context.Context, arg CreateRezingParams) (Zinger, error) {
+	row := q.db.QueryRowContext(ctx, createRezing,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.RezingOfID,
+	)
+	var i Zinger
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyToID,
+		&i.ConversationID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RezingOfID,
+		&i.QuoteOfID,
+		&i.RezingCount,
+		&i.QuoteCount,
+		&i.EditCount,
+		&i.FannedOut,
+	)
+	return i, err
+}
+
 const createZinger = `-- name: CreateZinger :one
-INSERT INTO zingers (id, created_at, updated_at, body, user_id)
+INSERT INTO zingers (id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, quote_of_id)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7,
+    $8
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out
 `
 
 type CreateZingerParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.UUID
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	InReplyToID    uuid.NullUUID
+	ConversationID uuid.UUID
+	QuoteOfID      uuid.NullUUID
 }
 
 func (q *Queries) CreateZinger(ctx context.Context, arg CreateZingerParams) (Zinger, error) {
@@ -39,6 +98,9 @@ func (q *Queries) CreateZinger(ctx context.Context, arg CreateZingerParams) (Zin
 		arg.UpdatedAt,
 		arg.Body,
 		arg.UserID,
+		arg.InReplyToID,
+		arg.ConversationID,
+		arg.QuoteOfID,
 	)
 	var i Zinger
 	err := row.Scan(
@@ -47,10 +109,83 @@ func (q *Queries) CreateZinger(ctx context.Context, arg CreateZingerParams) (Zin
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyToID,
+		&i.ConversationID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RezingOfID,
+		&i.QuoteOfID,
+		&i.RezingCount,
+		&i.QuoteCount,
+		&i.EditCount,
+		&i.FannedOut,
 	)
 	return i, err
 }
 
+const decrementLikeCount = `-- name: DecrementLikeCount :exec
+UPDATE zingers SET like_count = like_count - 1 WHERE id = $1
+`
+
+func (q *Queries) DecrementLikeCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementLikeCount, id)
+	return err
+}
+
+const decrementQuoteCount = `-- name: DecrementQuoteCount :exec
+UPDATE zingers SET quote_count = quote_count - 1 WHERE id = $1
+`
+
+func (q *Queries) DecrementQuoteCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementQuoteCount, id)
+	return err
+}
+
+const decrementReplyCount = `-- name: DecrementReplyCount :exec
+UPDATE zingers SET reply_count = reply_count - 1 WHERE id = $1
+`
+
+func (q *Queries) DecrementReplyCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementReplyCount, id)
+	return err
+}
+
+const decrementRezingCount = `-- name: DecrementRezingCount :exec
+UPDATE zingers SET rezing_count = rezing_count - 1 WHERE id = $1
+`
+
+func (q *Queries) DecrementRezingCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementRezingCount, id)
+	return err
+}
+
+const deleteRezing = `-- name: DeleteRezing :one
+DELETE FROM zingers WHERE user_id = $1 AND rezing_of_id = $2
+RETURNING id
+`
+
+type DeleteRezingParams struct {
+	UserID     uuid.UUID
+	RezingOfID uuid.NullUUID
+}
+
+func (q *Queries) DeleteRezing(ctx context.Context, arg DeleteRezingParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, deleteRezing, arg.UserID, arg.RezingOfID)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const deleteRezingsOf = `-- name: DeleteRezingsOf :exec
+DELETE FROM zingers WHERE rezing_of_id = $1
+`
+
+func (q *Queries) DeleteRezingsOf(ctx context.Context, rezingOfID uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRezingsOf, rezingOfID)
+	return err
+}
+
 const deleteZingerById = `-- name: DeleteZingerById :exec
 DELETE FROM zingers WHERE id = $1
 `
@@ -60,12 +195,58 @@ func (q *Queries) DeleteZingerById(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const getAllZingers = `-- name: GetAllZingers :many
-SELECT id, created_at, updated_at, body, user_id FROM zingers ORDER BY created_at ASC
+const editZinger = `-- name: EditZinger :one
+UPDATE zingers SET body = $1, updated_at = $2, edit_count = edit_count + 1 WHERE id = $3
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out
+`
+
+type EditZingerParams struct {
+	Body      string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) EditZinger(ctx context.Context, arg EditZingerParams) (Zinger, error) {
+	row := q.db.QueryRowContext(ctx, editZinger, arg.Body, arg.UpdatedAt, arg.ID)
+	var i Zinger
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyToID,
+		&i.ConversationID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RezingOfID,
+		&i.QuoteOfID,
+		&i.RezingCount,
+		&i.QuoteCount,
+		&i.EditCount,
+		&i.FannedOut,
+	)
+	return i, err
+}
+
+const getZingerAncestors = `-- name: GetZingerAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.*, 1 AS distance
+    FROM zingers child
+    JOIN zingers parent ON parent.id = child.in_reply_to_id
+    WHERE child.id = $1
+    UNION ALL
+    SELECT parent.*, ancestors.distance + 1
+    FROM ancestors
+    JOIN zingers parent ON parent.id = ancestors.in_reply_to_id
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out FROM ancestors
+ORDER BY distance DESC
 `
 
-func (q *Queries) GetAllZingers(ctx context.Context) ([]Zinger, error) {
-	rows, err := q.db.QueryContext(ctx, getAllZingers)
+func (q *Queries) GetZingerAncestors(ctx context.Context, id uuid.UUID) ([]Zinger, error) {
+	rows, err := q.db.QueryContext(ctx, getZingerAncestors, id)
 	if err != nil {
 		return nil, err
 	}
@@ -79,6 +260,17 @@ func (q *Queries) GetAllZingers(ctx context.Context) ([]Zinger, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyToID,
+			&i.ConversationID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RezingOfID,
+			&i.QuoteOfID,
+			&i.RezingCount,
+			&i.QuoteCount,
+			&i.EditCount,
+			&i.FannedOut,
 		); err != nil {
 			return nil, err
 		}
@@ -94,7 +286,7 @@ func (q *Queries) GetAllZingers(ctx context.Context) ([]Zinger, error) {
 }
 
 const getZingerById = `-- name: GetZingerById :one
-SELECT id, created_at, updated_at, body, user_id FROM zingers WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out FROM zingers WHERE id = $1
 `
 
 func (q *Queries) GetZingerById(ctx context.Context, id uuid.UUID) (Zinger, error) {
@@ -106,16 +298,141 @@ func (q *Queries) GetZingerById(ctx context.Context, id uuid.UUID) (Zinger, erro
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyToID,
+		&i.ConversationID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RezingOfID,
+		&i.QuoteOfID,
+		&i.RezingCount,
+		&i.QuoteCount,
+		&i.EditCount,
+		&i.FannedOut,
+	)
+	return i, err
+}
+
+const getZingerByIdForUpdate = `-- name: GetZingerByIdForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out FROM zingers WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetZingerByIdForUpdate(ctx context.Context, id uuid.UUID) (Zinger, error) {
+	row := q.db.QueryRowContext(ctx, getZingerByIdForUpdate, id)
+	var i Zinger
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyToID,
+		&i.ConversationID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RezingOfID,
+		&i.QuoteOfID,
+		&i.RezingCount,
+		&i.QuoteCount,
+		&i.EditCount,
+		&i.FannedOut,
 	)
 	return i, err
 }
 
-const getZingersByUser = `-- name: GetZingersByUser :many
-SELECT id, created_at, updated_at, body, user_id FROM zingers WHERE user_id = $1 ORDER BY created_at ASC
+const getZingerDescendants = `-- name: GetZingerDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT zingers.*, 1 AS depth,
+        to_char(zingers.created_at, 'YYYYMMDDHH24MISSUS') || zingers.id::text AS path
+    FROM zingers
+    WHERE zingers.in_reply_to_id = $1
+    UNION ALL
+    SELECT zingers.*, descendants.depth + 1,
+        descendants.path || '/' || to_char(zingers.created_at, 'YYYYMMDDHH24MISSUS') || zingers.id::text
+    FROM descendants
+    JOIN zingers ON zingers.in_reply_to_id = descendants.id
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out, depth FROM descendants
+WHERE $2::uuid IS NULL
+    OR path COLLATE "C" > (SELECT path FROM descendants WHERE id = $2) COLLATE "C"
+ORDER BY path COLLATE "C"
+LIMIT $3
 `
 
-func (q *Queries) GetZingersByUser(ctx context.Context, userID uuid.UUID) ([]Zinger, error) {
-	rows, err := q.db.QueryContext(ctx, getZingersByUser, userID)
+type GetZingerDescendantsParams struct {
+	ZingerID uuid.UUID
+	AfterID  uuid.NullUUID
+	RowLimit int32
+}
+
+type GetZingerDescendantsRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	InReplyToID    uuid.NullUUID
+	ConversationID uuid.UUID
+	ReplyCount     int32
+	DeletedAt      sql.NullTime
+	LikeCount      int32
+	RezingOfID     uuid.NullUUID
+	QuoteOfID      uuid.NullUUID
+	RezingCount    int32
+	QuoteCount     int32
+	EditCount      int32
+	FannedOut      bool
+	Depth          int32
+}
+
+func (q *Queries) GetZingerDescendants(ctx context.Context, arg GetZingerDescendantsParams) ([]GetZingerDescendantsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getZingerDescendants, arg.ZingerID, arg.AfterID, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetZingerDescendantsRow
+	for rows.Next() {
+		var i GetZingerDescendantsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyToID,
+			&i.ConversationID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RezingOfID,
+			&i.QuoteOfID,
+			&i.RezingCount,
+			&i.QuoteCount,
+			&i.EditCount,
+			&i.FannedOut,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getZingersByIds = `-- name: GetZingersByIds :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out FROM zingers WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetZingersByIds(ctx context.Context, ids []uuid.UUID) ([]Zinger, error) {
+	rows, err := q.db.QueryContext(ctx, getZingersByIds, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -129,6 +446,17 @@ func (q *Queries) GetZingersByUser(ctx context.Context, userID uuid.UUID) ([]Zin
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyToID,
+			&i.ConversationID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RezingOfID,
+			&i.QuoteOfID,
+			&i.RezingCount,
+			&i.QuoteCount,
+			&i.EditCount,
+			&i.FannedOut,
 		); err != nil {
 			return nil, err
 		}
@@ -142,3 +470,200 @@ func (q *Queries) GetZingersByUser(ctx context.Context, userID uuid.UUID) ([]Zin
 	}
 	return items, nil
 }
+
+const incrementLikeCount = `-- name: IncrementLikeCount :execrows
+UPDATE zingers SET like_count = like_count + 1 WHERE id = $1 AND deleted_at IS NULL AND rezing_of_id IS NULL
+`
+
+func (q *Queries) IncrementLikeCount(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, incrementLikeCount, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const incrementQuoteCount = `-- name: IncrementQuoteCount :execrows
+UPDATE zingers SET quote_count = quote_count + 1 WHERE id = $1 AND deleted_at IS NULL AND rezing_of_id IS NULL
+`
+
+func (q *Queries) IncrementQuoteCount(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, incrementQuoteCount, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const incrementReplyCount = `-- name: IncrementReplyCount :execrows
+UPDATE zingers SET reply_count = reply_count + 1 WHERE id = $1 AND deleted_at IS NULL AND rezing_of_id IS NULL
+`
+
+func (q *Queries) IncrementReplyCount(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, incrementReplyCount, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const incrementRezingCount = `-- name: IncrementRezingCount :execrows
+UPDATE zingers SET rezing_count = rezing_count + 1 WHERE id = $1 AND deleted_at IS NULL AND rezing_of_id IS NULL
+`
+
+func (q *Queries) IncrementRezingCount(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, incrementRezingCount, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listZingersAsc = `-- name: ListZingersAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out FROM zingers
+WHERE deleted_at IS NULL
+    AND ($1::uuid IS NULL OR user_id = $1)
+    AND ($2::timestamp IS NULL OR created_at >= $2)
+    AND ($3::timestamp IS NULL OR created_at < $3)
+    AND ($4::timestamp IS NULL OR (created_at, id) > ($4, $5::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $6
+`
+
+type ListZingersAscParams struct {
+	UserID         uuid.NullUUID
+	Since          sql.NullTime
+	Until          sql.NullTime
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	RowLimit       int32
+}
+
+func (q *Queries) ListZingersAsc(ctx context.Context, arg ListZingersAscParams) ([]Zinger, error) {
+	rows, err := q.db.QueryContext(ctx, listZingersAsc,
+		arg.UserID,
+		arg.Since,
+		arg.Until,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Zinger
+	for rows.Next() {
+		var i Zinger
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyToID,
+			&i.ConversationID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RezingOfID,
+			&i.QuoteOfID,
+			&i.RezingCount,
+			&i.QuoteCount,
+			&i.EditCount,
+			&i.FannedOut,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listZingersDesc = `-- name: ListZingersDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out FROM zingers
+WHERE deleted_at IS NULL
+    AND ($1::uuid IS NULL OR user_id = $1)
+    AND ($2::timestamp IS NULL OR created_at >= $2)
+    AND ($3::timestamp IS NULL OR created_at < $3)
+    AND ($4::timestamp IS NULL OR (created_at, id) < ($4, $5::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $6
+`
+
+type ListZingersDescParams struct {
+	UserID         uuid.NullUUID
+	Since          sql.NullTime
+	Until          sql.NullTime
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	RowLimit       int32
+}
+
+func (q *Queries) ListZingersDesc(ctx context.Context, arg ListZingersDescParams) ([]Zinger, error) {
+	rows, err := q.db.QueryContext(ctx, listZingersDesc,
+		arg.UserID,
+		arg.Since,
+		arg.Until,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Zinger
+	for rows.Next() {
+		var i Zinger
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyToID,
+			&i.ConversationID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RezingOfID,
+			&i.QuoteOfID,
+			&i.RezingCount,
+			&i.QuoteCount,
+			&i.EditCount,
+			&i.FannedOut,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const tombstoneZinger = `-- name: TombstoneZinger :exec
+UPDATE zingers SET body = '', rezing_count = 0, deleted_at = $1, updated_at = $2 WHERE id = $3
+`
+
+type TombstoneZingerParams struct {
+	DeletedAt sql.NullTime
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) TombstoneZinger(ctx context.Context, arg TombstoneZingerParams) error {
+	_, err := q.db.ExecContext(ctx, tombstoneZinger, arg.DeletedAt, arg.UpdatedAt, arg.ID)
+	return err
+}
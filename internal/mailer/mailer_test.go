@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	m := &LogMailer{Path: path, From: "ZingZing <no-reply@zingzing.test>"}
+
+	err := m.Send(context.Background(), Message{To: "ada@example.com", Subject: "Hello", Body: "line one\nline two"})
+	require.NoError(t, err)
+	err = m.Send(context.Background(), Message{To: "bob@example.com", Subject: "Again", Body: "hi"})
+	require.NoError(t, err)
+
+	dat, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(dat), "To: ada@example.com\r\n")
+	assert.Contains(t, string(dat), "Subject: Hello\r\n")
+	assert.Contains(t, string(dat), "line one\r\nline two")
+	assert.Contains(t, string(dat), "To: bob@example.com\r\n")
+}
@@ -0,0 +1,116 @@
+package zingpay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Client calls the ZingPay API.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the API at baseURL, authenticated with
+// apiKey.
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// APIError is a response from ZingPay other than a success.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("zingpay: %d %s", e.StatusCode, e.Message)
+}
+
+// CheckoutSessionParams describe a checkout for a plan. ZingPay sends the
+// customer to SuccessURL or CancelURL once they are done, and the webhooks
+// of the resulting subscription carry UserID.
+type CheckoutSessionParams struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Email      string    `json:"email"`
+	Plan       string    `json:"plan"`
+	SuccessURL string    `json:"success_url"`
+	CancelURL  string    `json:"cancel_url"`
+}
+
+// CheckoutSession is a hosted payment page. The customer pays at URL.
+type CheckoutSession struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Invoice is a charge ZingPay made, or tried to make, for a subscription.
+type Invoice struct {
+	ID        string    `json:"id"`
+	Plan      string    `json:"plan"`
+	Amount    int64     `json:"amount"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateCheckoutSession starts a checkout.
+func (c *Client) CreateCheckoutSession(ctx context.Context, params CheckoutSessionParams) (CheckoutSession, error) {
+	var session CheckoutSession
+	err := c.do(ctx, "POST", "/v1/checkout/sessions", params, &session)
+	return session, err
+}
+
+// ListInvoices returns the invoices of a user, newest first.
+func (c *Client) ListInvoices(ctx context.Context, userID uuid.UUID) ([]Invoice, error) {
+	var resp struct {
+		Data []Invoice `json:"data"`
+	}
+	err := c.do(ctx, "GET", "/v1/invoices?user_id="+url.QueryEscape(userID.String()), nil, &resp)
+	return resp.Data, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errBody struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errBody)
+		if errBody.Error == "" {
+			errBody.Error = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: errBody.Error}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
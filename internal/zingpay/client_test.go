@@ -0,0 +1,53 @@
+package zingpay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	userID := uuid.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk_test" {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error":"invalid API key"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/checkout/sessions":
+			var params CheckoutSessionParams
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+			assert.Equal(t, userID, params.UserID)
+			w.Write([]byte(`{"id":"cs_1","url":"https://pay.example/cs_1"}`))
+		case "/v1/invoices":
+			assert.Equal(t, userID.String(), r.URL.Query().Get("user_id"))
+			w.Write([]byte(`{"data":[{"id":"in_1","amount":499,"currency":"usd","status":"paid"}]}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL+"/", "sk_test")
+	session, err := client.CreateCheckoutSession(context.Background(), CheckoutSessionParams{UserID: userID, Plan: "red"})
+	require.NoError(t, err)
+	assert.Equal(t, CheckoutSession{ID: "cs_1", URL: "https://pay.example/cs_1"}, session)
+
+	invoices, err := client.ListInvoices(context.Background(), userID)
+	require.NoError(t, err)
+	require.Len(t, invoices, 1)
+	assert.Equal(t, int64(499), invoices[0].Amount)
+
+	_, err = NewClient(srv.URL, "sk_wrong").ListInvoices(context.Background(), userID)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 401, apiErr.StatusCode)
+	assert.Equal(t, "invalid API key", apiErr.Message)
+}
@@ -0,0 +1,124 @@
+// Package zingpay talks to ZingPay, the payment provider behind ZingZing Red.
+package zingpay
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SignatureHeader carries the signature of a webhook delivery, in the form
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256>". ZingPay sends one v1 entry per
+// active signing secret while a secret is being rotated.
+const SignatureHeader = "ZingPay-Signature"
+
+// DefaultTolerance is how far a delivery's timestamp may be from our clock.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrMissingSignature        = errors.New("missing webhook signature")
+	ErrMalformedSignature      = errors.New("malformed webhook signature")
+	ErrTimestampOutOfTolerance = errors.New("webhook timestamp outside tolerance")
+	ErrSignatureMismatch       = errors.New("webhook signature does not match")
+)
+
+// Event is a webhook delivery. ID is unique per event and stays the same when
+// ZingPay retries a delivery.
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// computeSignature is the v1 scheme: HMAC-SHA256 over "<timestamp>.<body>".
+func computeSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeaderValue signs body with every secret, the way ZingPay does.
+func SignatureHeaderValue(timestamp time.Time, body []byte, secrets ...string) string {
+	parts := []string{"t=" + strconv.FormatInt(timestamp.Unix(), 10)}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+computeSignature(secret, timestamp.Unix(), body))
+	}
+	return strings.Join(parts, ",")
+}
+
+// VerifySignature checks header against the raw request body. The delivery
+// is accepted if any v1 signature was made with any of secrets, which lets an
+// old and a new secret overlap during rotation, and its timestamp is within
+// tolerance of now. The timestamp is covered by the signature, so a captured
+// delivery can't be replayed once it falls outside the window.
+func VerifySignature(header string, body []byte, secrets []string, tolerance time.Duration, now time.Time) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrMalformedSignature
+		}
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrMalformedSignature
+			}
+			timestamp = t
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrMalformedSignature
+	}
+
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrTimestampOutOfTolerance
+	}
+
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		expected := []byte(computeSignature(secret, timestamp, body))
+		for _, signature := range signatures {
+			if hmac.Equal(expected, []byte(signature)) {
+				return nil
+			}
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+// Event types ZingPay sends about subscriptions to ZingZing Red.
+const (
+	EventUserUpgraded          = "user.upgraded"
+	EventUserDowngraded        = "user.downgraded"
+	EventSubscriptionRenewed   = "subscription.renewed"
+	EventSubscriptionCancelled = "subscription.cancelled"
+	EventPaymentFailed         = "payment.failed"
+)
+
+// SubscriptionData is the data of every subscription event. Plan and
+// CurrentPeriodEnd are set for upgrades and renewals.
+type SubscriptionData struct {
+	UserID           uuid.UUID `json:"user_id"`
+	Plan             string    `json:"plan,omitempty"`
+	CurrentPeriodEnd time.Time `json:"current_period_end,omitzero"`
+}
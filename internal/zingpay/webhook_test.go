@@ -0,0 +1,47 @@
+package zingpay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"id":"evt_1","event":"user.upgraded","data":{"user_id":"x"}}`)
+	now := time.Unix(1700000000, 0)
+	header := SignatureHeaderValue(now, body, "new-secret")
+
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, VerifySignature(header, body, []string{"new-secret"}, DefaultTolerance, now.Add(time.Minute)))
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		assert.NoError(t, VerifySignature(header, body, []string{"other", "new-secret"}, DefaultTolerance, now), "new secret listed second")
+		oldHeader := SignatureHeaderValue(now, body, "old-secret")
+		assert.NoError(t, VerifySignature(oldHeader, body, []string{"new-secret", "old-secret"}, DefaultTolerance, now), "old secret still accepted")
+		both := SignatureHeaderValue(now, body, "old-secret", "new-secret")
+		assert.NoError(t, VerifySignature(both, body, []string{"new-secret"}, DefaultTolerance, now), "ZingPay signing with both")
+	})
+
+	t.Run("Tampered body", func(t *testing.T) {
+		assert.ErrorIs(t, VerifySignature(header, append(body, ' '), []string{"new-secret"}, DefaultTolerance, now), ErrSignatureMismatch)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		assert.ErrorIs(t, VerifySignature(header, body, []string{"guess"}, DefaultTolerance, now), ErrSignatureMismatch)
+		assert.ErrorIs(t, VerifySignature(header, body, []string{""}, DefaultTolerance, now), ErrSignatureMismatch)
+	})
+
+	t.Run("Replayed later", func(t *testing.T) {
+		assert.ErrorIs(t, VerifySignature(header, body, []string{"new-secret"}, DefaultTolerance, now.Add(6*time.Minute)), ErrTimestampOutOfTolerance)
+		assert.ErrorIs(t, VerifySignature(header, body, []string{"new-secret"}, DefaultTolerance, now.Add(-6*time.Minute)), ErrTimestampOutOfTolerance)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		assert.ErrorIs(t, VerifySignature("", body, []string{"new-secret"}, DefaultTolerance, now), ErrMissingSignature)
+		for _, bad := range []string{"garbage", "t=abc,v1=00", "v1=00", "t=1700000000"} {
+			assert.ErrorIs(t, VerifySignature(bad, body, []string{"new-secret"}, DefaultTolerance, now), ErrMalformedSignature, bad)
+		}
+	})
+}
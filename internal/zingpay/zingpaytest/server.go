@@ -0,0 +1,135 @@
+package zingpaytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+	"github.com/google/uuid"
+)
+
+// PlanPrice is what the fake charges for any plan, in cents.
+const PlanPrice = 499
+
+// Server is a fake ZingPay API. Paying for a checkout session records an
+// invoice and delivers a signed user.upgraded webhook, like ZingPay would.
+type Server struct {
+	*httptest.Server
+
+	apiKey        string
+	webhookURL    string
+	webhookSecret string
+
+	mu       sync.Mutex
+	sessions map[string]zingpay.CheckoutSessionParams
+	invoices map[uuid.UUID][]zingpay.Invoice
+}
+
+// NewServer starts a fake ZingPay API that accepts apiKey and delivers its
+// webhooks to webhookURL, signed with webhookSecret. Close it when done.
+func NewServer(apiKey, webhookURL, webhookSecret string) *Server {
+	s := &Server{
+		apiKey:        apiKey,
+		webhookURL:    webhookURL,
+		webhookSecret: webhookSecret,
+		sessions:      map[string]zingpay.CheckoutSessionParams{},
+		invoices:      map[uuid.UUID][]zingpay.Invoice{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/checkout/sessions", s.authorized(s.createCheckoutSession))
+	mux.HandleFunc("GET /v1/invoices", s.authorized(s.listInvoices))
+	mux.HandleFunc("POST /checkout/{sessionID}", s.pay)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Pay completes the checkout session with the given ID, as the customer
+// would on the hosted payment page, and returns once the webhook has been
+// delivered.
+func (s *Server) Pay(sessionID string) error {
+	s.mu.Lock()
+	params, ok := s.sessions[sessionID]
+	delete(s.sessions, sessionID)
+	if ok {
+		now := time.Now().UTC()
+		invoice := zingpay.Invoice{ID: "in_" + uuid.NewString(), Plan: params.Plan, Amount: PlanPrice, Currency: "usd", Status: "paid", CreatedAt: now}
+		s.invoices[params.UserID] = append([]zingpay.Invoice{invoice}, s.invoices[params.UserID]...)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no open checkout session %s", sessionID)
+	}
+
+	event := NewEvent(zingpay.EventUserUpgraded, zingpay.SubscriptionData{UserID: params.UserID, Plan: params.Plan, CurrentPeriodEnd: time.Now().UTC().AddDate(0, 1, 0)})
+	status, err := Deliver(s.webhookURL, event, s.webhookSecret)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusNoContent {
+		return fmt.Errorf("webhook delivery got status %d", status)
+	}
+	return nil
+}
+
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+			respond(w, http.StatusUnauthorized, map[string]string{"error": "invalid API key"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) createCheckoutSession(w http.ResponseWriter, r *http.Request) {
+	var params zingpay.CheckoutSessionParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.UserID == uuid.Nil || params.Plan == "" {
+		respond(w, http.StatusBadRequest, map[string]string{"error": "user_id and plan are required"})
+		return
+	}
+	id := "cs_" + uuid.NewString()
+	s.mu.Lock()
+	s.sessions[id] = params
+	s.mu.Unlock()
+	respond(w, http.StatusCreated, zingpay.CheckoutSession{ID: id, URL: s.URL + "/checkout/" + id, ExpiresAt: time.Now().UTC().Add(time.Hour)})
+}
+
+func (s *Server) listInvoices(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		respond(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+		return
+	}
+	s.mu.Lock()
+	invoices := append([]zingpay.Invoice{}, s.invoices[userID]...)
+	s.mu.Unlock()
+	respond(w, http.StatusOK, map[string]interface{}{"data": invoices})
+}
+
+// pay is the hosted payment page. It sends the customer back to the
+// session's success URL once the payment went through.
+func (s *Server) pay(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("sessionID")
+	s.mu.Lock()
+	params, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if !ok {
+		respond(w, http.StatusNotFound, map[string]string{"error": "no such checkout session"})
+		return
+	}
+	if err := s.Pay(sessionID); err != nil {
+		respond(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	http.Redirect(w, r, params.SuccessURL, http.StatusSeeOther)
+}
+
+func respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
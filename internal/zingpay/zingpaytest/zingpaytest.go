@@ -0,0 +1,53 @@
+// Package zingpaytest fakes ZingPay, its API and its webhook deliveries, for
+// tests and local development.
+package zingpaytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+	"github.com/google/uuid"
+)
+
+// NewEvent builds an event of the given type with a fresh ID.
+func NewEvent(eventType string, data interface{}) zingpay.Event {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	return zingpay.Event{ID: "evt_" + uuid.NewString(), Type: eventType, CreatedAt: time.Now().UTC(), Data: dat}
+}
+
+// NewRequest returns a POST of event to url, signed at timestamp with each
+// of secrets.
+func NewRequest(url string, event zingpay.Event, timestamp time.Time, secrets ...string) (*http.Request, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(zingpay.SignatureHeader, zingpay.SignatureHeaderValue(timestamp, body, secrets...))
+	return req, nil
+}
+
+// Deliver signs event with secret, posts it to url and returns the status
+// code of the response.
+func Deliver(url string, event zingpay.Event, secret string) (int, error) {
+	req, err := NewRequest(url, event, time.Now(), secret)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadJWTKeysNeedsActiveKey(t *testing.T) {
+	t.Setenv("JWT_KEYS_DIR", t.TempDir())
+	t.Setenv("JWT_SECRET", "legacy-secret")
+	t.Setenv("JWT_ACTIVE_KEY_ID", "")
+	_, err := loadJWTKeys()
+	assert.Error(t, err, "the shared secret must not become the signing key")
+}
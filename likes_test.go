@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zingerList is the response of lists of zingers.
+type zingerList struct {
+	Zingers    []zingerResponse `json:"zingers"`
+	NextCursor *string          `json:"next_cursor"`
+}
+
+func TestLikes(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	alice, aliceToken := createTestUser(t, cfg)
+	bob, bobToken := createTestUser(t, cfg)
+
+	var zinger zingerResponse
+	resp := doJSON(t, "POST", api.URL+"/api/zingers", aliceToken, map[string]string{"body": "like me"}, &zinger)
+	require.Equal(t, 201, resp.StatusCode)
+	likeURL := api.URL + "/api/zingers/" + zinger.Id.String() + "/like"
+
+	var got zingerResponse
+	for i := 0; i < 2; i++ {
+		resp = doJSON(t, "POST", likeURL, bobToken, nil, &got)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, int32(1), got.LikeCount, "liking twice counts once")
+		require.NotNil(t, got.ViewerLiked)
+		assert.True(t, *got.ViewerLiked)
+	}
+	resp = doJSON(t, "POST", api.URL+"/api/zingers/"+uuid.NewString()+"/like", bobToken, nil, nil)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	t.Run("ViewerLiked", func(t *testing.T) {
+		resp := doJSON(t, "GET", api.URL+"/api/zingers/"+zinger.Id.String(), aliceToken, nil, &got)
+		require.Equal(t, 200, resp.StatusCode)
+		require.NotNil(t, got.ViewerLiked)
+		assert.False(t, *got.ViewerLiked)
+
+		resp = doJSON(t, "GET", api.URL+"/api/zingers/"+zinger.Id.String(), "", nil, &got)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Nil(t, got.ViewerLiked, "anonymous")
+		assert.Equal(t, int32(1), got.LikeCount)
+
+		var page zingerList
+		resp = doJSON(t, "GET", api.URL+"/api/zingers?author_id="+alice.ID.String(), bobToken, nil, &page)
+		require.Equal(t, 200, resp.StatusCode)
+		require.Len(t, page.Zingers, 1)
+		require.NotNil(t, page.Zingers[0].ViewerLiked)
+		assert.True(t, *page.Zingers[0].ViewerLiked)
+	})
+
+	t.Run("Lists", func(t *testing.T) {
+		var users followsPage
+		resp := doJSON(t, "GET", likeURL+"s", "", nil, &users)
+		require.Equal(t, 200, resp.StatusCode)
+		require.Len(t, users.Users, 1)
+		assert.Equal(t, bob.ID, users.Users[0].ID)
+		assert.NotNil(t, users.Users[0].LikedAt)
+
+		var zingers zingerList
+		resp = doJSON(t, "GET", api.URL+"/api/users/"+bob.ID.String()+"/likes", "", nil, &zingers)
+		require.Equal(t, 200, resp.StatusCode)
+		require.Len(t, zingers.Zingers, 1)
+		assert.Equal(t, zinger.Id, zingers.Zingers[0].Id)
+		assert.NotNil(t, zingers.Zingers[0].LikedAt)
+	})
+
+	t.Run("Unlike", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			resp := doJSON(t, "DELETE", likeURL, bobToken, nil, nil)
+			require.Equal(t, 204, resp.StatusCode)
+		}
+		resp := doJSON(t, "GET", api.URL+"/api/zingers/"+zinger.Id.String(), "", nil, &got)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, int32(0), got.LikeCount)
+	})
+}
+
+func TestLikesConcurrent(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	_, authorToken := createTestUser(t, cfg)
+	var zinger zingerResponse
+	resp := doJSON(t, "POST", api.URL+"/api/zingers", authorToken, map[string]string{"body": "popular"}, &zinger)
+	require.Equal(t, 201, resp.StatusCode)
+	likeURL := api.URL + "/api/zingers/" + zinger.Id.String() + "/like"
+
+	const likers = 10
+	tokens := make([]string, likers)
+	for i := range tokens {
+		_, tokens[i] = createTestUser(t, cfg)
+	}
+	var wg sync.WaitGroup
+	for _, token := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Every liker likes twice at once; each still counts once.
+			for i := 0; i < 2; i++ {
+				resp := doJSON(t, "POST", likeURL, token, nil, nil)
+				assert.Equal(t, 200, resp.StatusCode)
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := doJSON(t, "POST", likeURL, token, nil, nil)
+			assert.Equal(t, 200, resp.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	stored, err := cfg.dbq.GetZingerById(context.Background(), zinger.Id)
+	require.NoError(t, err)
+	assert.Equal(t, int32(likers), stored.LikeCount)
+
+	for _, token := range tokens[:likers/2] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := doJSON(t, "DELETE", likeURL, token, nil, nil)
+			assert.Equal(t, 204, resp.StatusCode)
+		}()
+	}
+	wg.Wait()
+	stored, err = cfg.dbq.GetZingerById(context.Background(), zinger.Id)
+	require.NoError(t, err)
+	assert.Equal(t, int32(likers-likers/2), stored.LikeCount)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginUpgradesPasswordHash(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	user, _ := createTestUser(t, cfg)
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	_, err = cfg.db.Exec("UPDATE users SET hashed_password = $1 WHERE id = $2", string(legacy), user.ID)
+	require.NoError(t, err)
+
+	resp := doJSON(t, "POST", api.URL+"/api/login", "", map[string]string{"email": user.Email, "password": "password"}, nil)
+	require.Equal(t, 200, resp.StatusCode)
+	got, err := cfg.dbq.GetUserByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got.HashedPassword, "$argon2id$"), got.HashedPassword)
+	_, err = cfg.hasher.Check(got.HashedPassword, "password")
+	assert.NoError(t, err)
+
+	t.Run("Password changed meanwhile", func(t *testing.T) {
+		cfg.upgradePasswordHash(user.ID, string(legacy), "password")
+		after, err := cfg.dbq.GetUserByID(context.Background(), user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, got.HashedPassword, after.HashedPassword)
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	cfg.account_lockout = auth.LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+	cfg.ip_lockout = auth.LockoutPolicy{Threshold: 100, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+	alice, _ := createTestUser(t, cfg)
+	bob, _ := createTestUser(t, cfg)
+	t.Cleanup(func() {
+		cfg.dbq.ClearLoginThrottle(context.Background(), "email:"+strings.ToLower(alice.Email))
+		cfg.dbq.ClearLoginThrottle(context.Background(), "email:"+strings.ToLower(bob.Email))
+		cfg.dbq.ClearLoginThrottle(context.Background(), "ip:127.0.0.1")
+	})
+	login := func(email, password string) *http.Response {
+		t.Helper()
+		return doJSON(t, "POST", api.URL+"/api/login", "", map[string]string{"email": email, "password": password}, nil)
+	}
+
+	t.Run("Locked out", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, 401, login(alice.Email, "wrong").StatusCode)
+		}
+		resp := login(alice.Email, "password")
+		assert.Equal(t, 429, resp.StatusCode, "even with the right password")
+		retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		require.NoError(t, err)
+		assert.Greater(t, retryAfter, 0)
+		assert.LessOrEqual(t, retryAfter, 60)
+	})
+
+	t.Run("Success clears the failures", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, 401, login(bob.Email, "wrong").StatusCode)
+		}
+		assert.Equal(t, 200, login(bob.Email, "password").StatusCode)
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, 401, login(bob.Email, "wrong").StatusCode)
+		}
+		assert.Equal(t, 200, login(bob.Email, "password").StatusCode)
+	})
+}
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"regexp"
 	"sync/atomic"
+	"time"
 
+	"github.com/bsuvonov/zingzing/internal/auth"
 	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/bsuvonov/zingzing/internal/mailer"
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -17,9 +25,26 @@ import (
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db *sql.DB
 	dbq *database.Queries
-	jwt_secret string
-	zingpay_key string
+	jwt_keys *auth.KeySet
+	hasher *auth.PasswordHasher
+	mailer mailer.Mailer
+	base_url string
+	unverified_read_only bool
+	account_lockout auth.LockoutPolicy
+	ip_lockout auth.LockoutPolicy
+	reset_email_throttle auth.LockoutPolicy
+	reset_ip_throttle auth.LockoutPolicy
+	password_reset_sends chan struct{}
+	zingpay *zingpay.Client
+	zingpay_webhook_secrets []string
+	zingpay_webhook_tolerance time.Duration
+	webhook_wakeup chan struct{}
+	subscription_grace_period time.Duration
+	timeline_fanout_threshold int
+	zinger_edits zingerEditPolicy
+	zinger_length zingerLengthPolicy
 }
 
 
@@ -33,6 +58,93 @@ func (cfg *apiConfig) middlewareMetricInc(next http.Handler) http.Handler {
 
 
 
+// adminAction is one entry of the admin audit log. ActorID is unset for
+// actions taken from the command line.
+type adminAction struct {
+	ActorID  uuid.NullUUID
+	Action   string
+	TargetID uuid.NullUUID
+	Details  map[string]interface{}
+	IP       string
+}
+
+// newAdminAction starts an audit log entry for an action taken by the caller
+// of a requireRole route.
+func newAdminAction(r *http.Request, action string) adminAction {
+	p := principalFrom(r)
+	return adminAction{ActorID: uuid.NullUUID{UUID: p.UserID, Valid: true}, Action: action, IP: clientIP(r)}
+}
+
+// recordAdminAction writes action to the audit log. Pass a transaction as q
+// to keep the entry only if the action itself goes through.
+func recordAdminAction(ctx context.Context, q *database.Queries, action adminAction) error {
+	if action.Details == nil {
+		action.Details = map[string]interface{}{}
+	}
+	details, err := json.Marshal(action.Details)
+	if err != nil {
+		return err
+	}
+	return q.CreateAdminAuditLogEntry(ctx, database.CreateAdminAuditLogEntryParams{
+		ID:           uuid.New(),
+		ActorID:      action.ActorID,
+		Action:       action.Action,
+		TargetUserID: action.TargetID,
+		Details:      details,
+		IpAddress:    action.IP,
+		CreatedAt:    time.Now().UTC(),
+	})
+}
+
+// bootstrapAdmin promotes the user with the given email to admin, as long as
+// there is no admin yet. Later admins are appointed through the API.
+func bootstrapAdmin(cfg *apiConfig, email string) error {
+	user, err := cfg.dbq.GetUserByEmail(context.Background(), email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user with email %s", email)
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	rows, err := qtx.PromoteFirstAdmin(context.Background(), database.PromoteFirstAdminParams{UpdatedAt: time.Now().UTC(), ID: user.ID})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("an admin already exists, use PUT /admin/users/{userID}/role instead")
+	}
+	err = recordAdminAction(context.Background(), qtx, adminAction{
+		Action:   "bootstrap_admin",
+		TargetID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Details:  map[string]interface{}{"from": user.Role, "to": auth.RoleAdmin},
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+
+
+// clientIP returns the address of the peer that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+
+
 func censorZinger(input string) string {
 	// Regex for "stupid" (case-insensitive, optional ! at the end)
 	stupid := regexp.MustCompile(`(?i)\bstupid\b!?`)
@@ -73,10 +185,97 @@ func handleUserLoginError(w http.ResponseWriter, r *http.Request) {
 }
 
 
-func main() {
+// loadJWTKeys signs with the asymmetric keys in JWT_KEYS_DIR when it is set,
+// and falls back to HS256 with JWT_SECRET otherwise. If both are set the
+// shared secret is kept for verification only, so tokens issued before the
+// switch stay valid until they expire.
+func loadJWTKeys() (*auth.KeySet, error) {
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	secret := os.Getenv("JWT_SECRET")
+	if keysDir == "" {
+		return auth.NewHMACKeySet(secret), nil
+	}
+	keys, err := auth.LoadKeysFromDir(keysDir)
+	if err != nil {
+		return nil, err
+	}
+	activeID := os.Getenv("JWT_ACTIVE_KEY_ID")
+	if activeID == "" {
+		return nil, errors.New("JWT_ACTIVE_KEY_ID must name a private key in JWT_KEYS_DIR")
+	}
+	if secret != "" {
+		keys = append(keys, auth.NewHMACVerifyKey("", secret))
+	}
+	return auth.NewKeySet(keys, activeID)
+}
+
 
+// routes registers every endpoint of the API.
+func (cfg *apiConfig) routes() *http.ServeMux {
 	serverHandler := http.NewServeMux()
-	server := &http.Server{Addr: ":8080", Handler: serverHandler}
+	serverHandler.Handle("/app/", cfg.middlewareMetricInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
+	serverHandler.HandleFunc("GET /api/healthz", handlerHealthz)
+	serverHandler.HandleFunc("GET /.well-known/jwks.json", cfg.jwksHandler)
+	serverHandler.Handle("GET /admin/metrics", cfg.requireRole(auth.RoleModerator, cfg.metricsHandler))
+	serverHandler.Handle("POST /admin/reset", cfg.requireRole(auth.RoleAdmin, cfg.resetMetrics))
+	serverHandler.Handle("PUT /admin/users/{userID}/role", cfg.requireRole(auth.RoleAdmin, cfg.userRolePutHandler))
+	serverHandler.Handle("GET /admin/webhooks", cfg.requireRole(auth.RoleAdmin, cfg.webhookEventsGetHandler))
+	serverHandler.Handle("POST /admin/webhooks/{eventID}/replay", cfg.requireRole(auth.RoleAdmin, cfg.webhookEventReplayHandler))
+	serverHandler.HandleFunc("POST /api/users", cfg.postUsersHandler)
+	serverHandler.HandleFunc("POST /api/users/verify", cfg.verifyEmailHandler)
+	serverHandler.Handle("POST /api/users/verify/resend", cfg.requireAuth("", cfg.resendVerificationHandler))
+	serverHandler.Handle("POST /api/zingers", cfg.requireWriteAuth(auth.ScopeZingersWrite, cfg.zingersPostHandler))
+	serverHandler.HandleFunc("POST /api/login", cfg.userLoginHandler)
+	serverHandler.HandleFunc("POST /api/login/2fa", cfg.twoFactorLoginHandler)
+	serverHandler.Handle("POST /api/users/2fa/enroll", cfg.requireAuth("", cfg.twoFactorEnrollHandler))
+	serverHandler.Handle("POST /api/users/2fa/confirm", cfg.requireAuth("", cfg.twoFactorConfirmHandler))
+	serverHandler.Handle("GET /api/zingers", cfg.optionalAuth(auth.ScopeZingersRead, cfg.zingersGetHandler))
+	serverHandler.Handle("GET /api/zingers/limits", cfg.optionalAuth(auth.ScopeZingersRead, cfg.zingerLimitsGetHandler))
+	serverHandler.Handle("GET /api/timeline/home", cfg.requireAuth(auth.ScopeZingersRead, cfg.homeTimelineGetHandler))
+	serverHandler.Handle("GET /api/zingers/{zingerID}", cfg.optionalAuth(auth.ScopeZingersRead, cfg.zingerGetHandler))
+	serverHandler.Handle("GET /api/zingers/{zingerID}/context", cfg.optionalAuth(auth.ScopeZingersRead, cfg.zingerContextGetHandler))
+	serverHandler.Handle("POST /api/zingers/{zingerID}/like", cfg.requireWriteAuth(auth.ScopeZingersWrite, cfg.likePostHandler))
+	serverHandler.Handle("DELETE /api/zingers/{zingerID}/like", cfg.requireWriteAuth(auth.ScopeZingersWrite, cfg.likeDeleteHandler))
+	serverHandler.Handle("GET /api/zingers/{zingerID}/likes", cfg.optionalAuth(auth.ScopeZingersRead, cfg.zingerLikesGetHandler))
+	serverHandler.Handle("POST /api/zingers/{zingerID}/rezing", cfg.requireWriteAuth(auth.ScopeZingersWrite, cfg.rezingPostHandler))
+	serverHandler.Handle("DELETE /api/zingers/{zingerID}/rezing", cfg.requireWriteAuth(auth.ScopeZingersWrite, cfg.rezingDeleteHandler))
+	serverHandler.HandleFunc("POST /api/password/forgot", cfg.passwordForgotHandler)
+	serverHandler.HandleFunc("POST /api/password/reset", cfg.passwordResetHandler)
+	serverHandler.HandleFunc("POST /api/refresh", cfg.refreshHandler)
+	serverHandler.HandleFunc("POST /api/revoke", cfg.revokeHandler)
+	serverHandler.Handle("PUT /api/users", cfg.requireAuth("", cfg.putUsersHandler))
+	serverHandler.Handle("PATCH /api/users/me", cfg.requireWriteAuth(auth.ScopeProfileWrite, cfg.userMePatchHandler))
+	serverHandler.Handle("GET /api/users/{handle}", cfg.optionalAuth(auth.ScopeFollowsRead, cfg.userGetHandler))
+	serverHandler.Handle("POST /api/users/{userID}/follow", cfg.requireWriteAuth(auth.ScopeFollowsWrite, cfg.followPostHandler))
+	serverHandler.Handle("DELETE /api/users/{userID}/follow", cfg.requireWriteAuth(auth.ScopeFollowsWrite, cfg.followDeleteHandler))
+	serverHandler.Handle("GET /api/users/{userID}/followers", cfg.optionalAuth(auth.ScopeFollowsRead, cfg.followsGetHandler(false)))
+	serverHandler.Handle("GET /api/users/{userID}/following", cfg.optionalAuth(auth.ScopeFollowsRead, cfg.followsGetHandler(true)))
+	serverHandler.Handle("GET /api/users/{userID}/likes", cfg.optionalAuth(auth.ScopeZingersRead, cfg.userLikesGetHandler))
+	serverHandler.Handle("DELETE /api/zingers/{zingerID}", cfg.requireWriteAuth(auth.ScopeZingersWrite, cfg.zingersDeleteHandler))
+	serverHandler.Handle("PATCH /api/zingers/{zingerID}", cfg.requireWriteAuth(auth.ScopeZingersWrite, cfg.zingerPatchHandler))
+	serverHandler.Handle("GET /api/zingers/{zingerID}/history", cfg.optionalAuth(auth.ScopeZingersRead, cfg.zingerHistoryGetHandler))
+	serverHandler.HandleFunc("POST /api/zingpay/webhooks", cfg.webhookHandler)
+	serverHandler.Handle("POST /api/billing/checkout", cfg.requireWriteAuth("", cfg.billingCheckoutHandler))
+	serverHandler.Handle("GET /api/billing", cfg.requireAuth("", cfg.billingGetHandler))
+	serverHandler.Handle("GET /api/sessions", cfg.requireAuth("", cfg.sessionsGetHandler))
+	serverHandler.Handle("POST /api/tokens", cfg.requireWriteAuth("", cfg.tokensPostHandler))
+	serverHandler.Handle("GET /api/tokens", cfg.requireAuth("", cfg.tokensGetHandler))
+	serverHandler.Handle("DELETE /api/tokens/{tokenID}", cfg.requireAuth("", cfg.tokenDeleteHandler))
+	serverHandler.Handle("DELETE /api/sessions", cfg.requireAuth("", cfg.sessionsDeleteHandler))
+	serverHandler.Handle("DELETE /api/sessions/{sessionID}", cfg.requireAuth("", cfg.sessionDeleteHandler))
+	serverHandler.Handle("POST /api/oauth/clients", cfg.requireWriteAuth("", cfg.oauthClientsPostHandler))
+	serverHandler.Handle("GET /api/oauth/clients", cfg.requireAuth("", cfg.oauthClientsGetHandler))
+	serverHandler.Handle("DELETE /api/oauth/clients/{clientID}", cfg.requireAuth("", cfg.oauthClientDeleteHandler))
+	serverHandler.Handle("GET /api/oauth/authorize", cfg.requireAuth("", cfg.oauthAuthorizeGetHandler))
+	serverHandler.Handle("POST /api/oauth/authorize", cfg.requireWriteAuth("", cfg.oauthAuthorizePostHandler))
+	serverHandler.HandleFunc("POST /api/oauth/token", cfg.oauthTokenHandler)
+	serverHandler.HandleFunc("POST /api/oauth/revoke", cfg.oauthRevokeHandler)
+	return serverHandler
+}
+
+
+func main() {
+
 	godotenv.Load()
 	dbURL := os.Getenv("DB_URL")
 	db, err := sql.Open("postgres", dbURL)
@@ -85,24 +284,65 @@ func main() {
 		os.Exit(1)
 	}
 
-	apiCfg := apiConfig{dbq: database.New(db), jwt_secret: os.Getenv("JWT_SECRET"), zingpay_key: os.Getenv("ZINGPAY_KEY")}
+	jwtKeys, err := loadJWTKeys()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	hasher := auth.NewPasswordHasher(auth.Argon2Params{
+		Memory:      uint32(envInt("ARGON2_MEMORY_KIB", int(auth.DefaultArgon2Params.Memory))),
+		Iterations:  uint32(envInt("ARGON2_ITERATIONS", int(auth.DefaultArgon2Params.Iterations))),
+		Parallelism: uint8(envInt("ARGON2_PARALLELISM", int(auth.DefaultArgon2Params.Parallelism))),
+		SaltLength:  auth.DefaultArgon2Params.SaltLength,
+		KeyLength:   auth.DefaultArgon2Params.KeyLength,
+	})
+
+	accountLockout, ipLockout := loadLockoutPolicies()
+	resetEmailThrottle, resetIPThrottle := loadPasswordResetPolicies()
 
-	serverHandler.Handle("/app/", apiCfg.middlewareMetricInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
-	serverHandler.HandleFunc("GET /api/healthz", handlerHealthz)
-	serverHandler.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
-	serverHandler.HandleFunc("POST /admin/reset", apiCfg.resetMetrics)
-	serverHandler.HandleFunc("POST /api/users", apiCfg.postUsersHandler)
-	serverHandler.HandleFunc("POST /api/zingers", apiCfg.zingersPostHandler)
-	serverHandler.HandleFunc("POST /api/login", apiCfg.userLoginHandler)
-	serverHandler.HandleFunc("GET /api/zingers", apiCfg.zingersGetHandler)
-	serverHandler.HandleFunc("GET /api/zingers/{zingerID}", apiCfg.zingerGetHandler)
-	serverHandler.HandleFunc("POST /api/refresh", apiCfg.refreshHandler)
-	serverHandler.HandleFunc("POST /api/revoke", apiCfg.revokeHandler)
-	serverHandler.HandleFunc("PUT /api/users", apiCfg.putUsersHandler)
-	serverHandler.HandleFunc("DELETE /api/zingers/{zingerID}", apiCfg.zingersDeleteHandler)
-	serverHandler.HandleFunc("POST /api/zingpay/webhooks", apiCfg.webhookHandler)
+	apiCfg := apiConfig{
+		db:                        db,
+		dbq:                       database.New(db),
+		jwt_keys:                  jwtKeys,
+		hasher:                    hasher,
+		mailer:                    loadMailer(),
+		base_url:                  envString("APP_BASE_URL", "http://localhost:8080"),
+		unverified_read_only:      envBool("UNVERIFIED_READ_ONLY", true),
+		account_lockout:           accountLockout,
+		ip_lockout:                ipLockout,
+		reset_email_throttle:      resetEmailThrottle,
+		reset_ip_throttle:         resetIPThrottle,
+		password_reset_sends:      make(chan struct{}, envInt("PASSWORD_RESET_MAX_IN_FLIGHT", 16)),
+		zingpay:                   loadZingPayClient(),
+		zingpay_webhook_secrets:   loadWebhookSecrets(),
+		zingpay_webhook_tolerance: envDuration("ZINGPAY_WEBHOOK_TOLERANCE", zingpay.DefaultTolerance),
+		webhook_wakeup:            make(chan struct{}, 1),
+		subscription_grace_period: envDuration("SUBSCRIPTION_GRACE_PERIOD", 72*time.Hour),
+		timeline_fanout_threshold: envInt("TIMELINE_FANOUT_THRESHOLD", defaultTimelineFanoutThreshold),
+		zinger_edits:              loadZingerEditPolicy(),
+		zinger_length:             loadZingerLengthPolicy(),
+	}
+
+	// ./out bootstrap-admin <email> makes the first admin.
+	if len(os.Args) > 1 {
+		if os.Args[1] != "bootstrap-admin" || len(os.Args) != 3 {
+			fmt.Println("usage: out [bootstrap-admin <email>]")
+			os.Exit(2)
+		}
+		err = bootstrapAdmin(&apiCfg, os.Args[2])
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("%s is now an admin\n", os.Args[2])
+		return
+	}
 
+	go apiCfg.runWebhookWorker(context.Background(), envDuration("WEBHOOK_WORKER_INTERVAL", 5*time.Second))
+	go apiCfg.runSubscriptionExpiry(context.Background(), envDuration("SUBSCRIPTION_EXPIRY_INTERVAL", 10*time.Minute))
 
+	server := &http.Server{Addr: ":8080", Handler: apiCfg.routes()}
 
 	fmt.Println("Starting server on :8080")
 	err = server.ListenAndServe()
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/bsuvonov/zingzing/internal/mailer"
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Webhook secrets of the test API, as if in the middle of a rotation.
+const (
+	testWebhookSecret         = "whsec_test_new"
+	testPreviousWebhookSecret = "whsec_test_old"
+)
+
+// newTestAPI serves the API against the database in TEST_DB_URL, which must
+// have all migrations applied. Tests that need it are skipped without one.
+func newTestAPI(t *testing.T) (*apiConfig, *httptest.Server) {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	accountLockout, ipLockout := loadLockoutPolicies()
+	resetEmailThrottle, resetIPThrottle := loadPasswordResetPolicies()
+	cfg := &apiConfig{
+		db:       db,
+		dbq:      database.New(db),
+		jwt_keys: auth.NewHMACKeySet("test-secret"),
+		// Cheap parameters, the tests aren't about password hashing.
+		hasher:          auth.NewPasswordHasher(auth.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}),
+		mailer:          &mailer.LogMailer{Path: filepath.Join(t.TempDir(), "mail.log")},
+		base_url:        "http://localhost:8080",
+		account_lockout: accountLockout,
+		ip_lockout:      ipLockout,
+
+		reset_email_throttle: resetEmailThrottle,
+		reset_ip_throttle:    resetIPThrottle,
+		password_reset_sends: make(chan struct{}, 16),
+
+		zingpay_webhook_secrets:   []string{testWebhookSecret, testPreviousWebhookSecret},
+		zingpay_webhook_tolerance: zingpay.DefaultTolerance,
+		timeline_fanout_threshold: defaultTimelineFanoutThreshold,
+		zinger_edits:              loadZingerEditPolicy(),
+		zinger_length:             loadZingerLengthPolicy(),
+	}
+	srv := httptest.NewServer(cfg.routes())
+	t.Cleanup(srv.Close)
+	return cfg, srv
+}
+
+// createTestUser adds a user with a verified email address, removed again
+// when the test ends, and returns a first-party access token for them.
+func createTestUser(t *testing.T, cfg *apiConfig) (database.User, string) {
+	t.Helper()
+	hash, err := cfg.hasher.Hash("password")
+	require.NoError(t, err)
+	now := time.Now().UTC()
+	id := uuid.New()
+	user, err := cfg.dbq.CreateUser(context.Background(), database.CreateUserParams{ID: id, CreatedAt: now, UpdatedAt: now, Email: uuid.NewString() + "@example.com", HashedPassword: hash, Handle: defaultHandle(id)})
+	require.NoError(t, err)
+	t.Cleanup(func() { cfg.db.Exec("DELETE FROM users WHERE id = $1", user.ID) })
+
+	_, err = cfg.dbq.MarkUserEmailVerified(context.Background(), database.MarkUserEmailVerifiedParams{EmailVerifiedAt: sql.NullTime{Time: now, Valid: true}, UpdatedAt: now, ID: user.ID, Email: user.Email})
+	require.NoError(t, err)
+
+	token, err := cfg.jwt_keys.MakeJWT(user.ID, time.Hour)
+	require.NoError(t, err)
+	return user, token
+}
+
+// doJSON sends body as JSON with an optional bearer token, and decodes the
+// response into out unless it is nil.
+func doJSON(t *testing.T, method, url, token string, body, out interface{}) *http.Response {
+	t.Helper()
+	var reqBody bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+	req, err := http.NewRequest(method, url, &reqBody)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp
+}
+
+// createTestPersonalAccessToken mints a personal access token with scopes
+// for the owner of sessionToken.
+func createTestPersonalAccessToken(t *testing.T, api *httptest.Server, sessionToken string, scopes ...string) string {
+	t.Helper()
+	var pat personalAccessTokenResponse
+	body := map[string]interface{}{"name": "test", "scopes": scopes, "expires_at": time.Now().Add(time.Hour)}
+	resp := doJSON(t, "POST", api.URL+"/api/tokens", sessionToken, body, &pat)
+	require.Equal(t, 201, resp.StatusCode)
+	return pat.Token
+}
+
+// unverifyTestUser takes back the verification of a test user's email
+// address.
+func unverifyTestUser(t *testing.T, cfg *apiConfig, userID uuid.UUID) {
+	t.Helper()
+	_, err := cfg.db.Exec("UPDATE users SET email_verified_at = NULL WHERE id = $1", userID)
+	require.NoError(t, err)
+}
+
+// mailedToken returns the token of the last link mailed to the address to,
+// or an empty string if there is none yet.
+func mailedToken(t *testing.T, cfg *apiConfig, to string) string {
+	t.Helper()
+	dat, err := os.ReadFile(cfg.mailer.(*mailer.LogMailer).Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ""
+	}
+	require.NoError(t, err)
+	pattern := regexp.MustCompile(`(?s)To: ` + regexp.QuoteMeta(to) + `\r\n.*?token=([0-9a-f]+)`)
+	matches := pattern.FindAllStringSubmatch(string(dat), -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[len(matches)-1][1]
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOAuthClient plays a third-party app: it starts authorization requests,
+// receives the user back on its own redirect URI and talks to the token
+// endpoints.
+type fakeOAuthClient struct {
+	t        *testing.T
+	api      string
+	id       string
+	secret   string
+	callback *httptest.Server
+	received chan url.Values
+}
+
+func newFakeOAuthClient(t *testing.T, api string) *fakeOAuthClient {
+	c := &fakeOAuthClient{t: t, api: api, received: make(chan url.Values, 1)}
+	c.callback = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.received <- r.URL.Query()
+		w.Write([]byte("You can close this window."))
+	}))
+	t.Cleanup(c.callback.Close)
+	return c
+}
+
+func (c *fakeOAuthClient) redirectURI() string {
+	return c.callback.URL + "/callback"
+}
+
+// authorize runs the user's side of the flow with their first-party token and
+// returns what arrived at the redirect URI.
+func (c *fakeOAuthClient) authorize(userToken, scope, state, challenge string, approve bool) url.Values {
+	t := c.t
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.id},
+		"redirect_uri":          {c.redirectURI()},
+		"scope":                 {scope},
+		"state":                 {state},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {auth.PKCEMethodS256},
+	}
+
+	var consent struct {
+		ClientName      string   `json:"client_name"`
+		Scopes          []string `json:"scopes"`
+		ConsentRequired bool     `json:"consent_required"`
+	}
+	resp := doJSON(t, "GET", c.api+"/api/oauth/authorize?"+params.Encode(), userToken, nil, &consent)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "Fake App", consent.ClientName)
+	assert.Equal(t, strings.Fields(scope), consent.Scopes)
+
+	body := map[string]interface{}{"approve": approve}
+	for key := range params {
+		body[key] = params.Get(key)
+	}
+	var answer struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+	resp = doJSON(t, "POST", c.api+"/api/oauth/authorize", userToken, body, &answer)
+	require.Equal(t, 200, resp.StatusCode)
+
+	resp, err := http.Get(answer.RedirectTo)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return <-c.received
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	Error        string `json:"error"`
+}
+
+func (c *fakeOAuthClient) post(path string, form url.Values) (int, tokenResponse) {
+	req, err := http.NewRequest("POST", c.api+path, strings.NewReader(form.Encode()))
+	require.NoError(c.t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.id, c.secret)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(c.t, err)
+	defer resp.Body.Close()
+	var body tokenResponse
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func newPKCEVerifier(t *testing.T) string {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	_, userToken := createTestUser(t, cfg)
+	client := newFakeOAuthClient(t, api.URL)
+
+	var registered struct {
+		ID           string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	resp := doJSON(t, "POST", api.URL+"/api/oauth/clients", userToken, map[string]interface{}{
+		"name":          "Fake App",
+		"redirect_uris": []string{client.redirectURI()},
+		"confidential":  true,
+	}, &registered)
+	require.Equal(t, 201, resp.StatusCode)
+	require.NotEmpty(t, registered.ClientSecret)
+	client.id, client.secret = registered.ID, registered.ClientSecret
+
+	t.Run("Denied", func(t *testing.T) {
+		got := client.authorize(userToken, auth.ScopeZingersWrite, "deny-state", auth.PKCEChallenge(newPKCEVerifier(t)), false)
+		assert.Equal(t, "access_denied", got.Get("error"))
+		assert.Equal(t, "deny-state", got.Get("state"))
+		assert.Empty(t, got.Get("code"))
+	})
+
+	verifier := newPKCEVerifier(t)
+	got := client.authorize(userToken, auth.ScopeZingersWrite, "xyz", auth.PKCEChallenge(verifier), true)
+	require.Equal(t, "xyz", got.Get("state"))
+	code := got.Get("code")
+	require.NotEmpty(t, code)
+
+	exchange := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {client.redirectURI()}}
+
+	t.Run("Wrong verifier", func(t *testing.T) {
+		form := url.Values{"code_verifier": {newPKCEVerifier(t)}}
+		for key, value := range exchange {
+			form[key] = value
+		}
+		status, body := client.post("/api/oauth/token", form)
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "invalid_grant", body.Error)
+	})
+
+	exchange.Set("code_verifier", verifier)
+	status, tokens := client.post("/api/oauth/token", exchange)
+	require.Equal(t, 200, status)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, auth.ScopeZingersWrite, tokens.Scope)
+
+	t.Run("Scopes are enforced", func(t *testing.T) {
+		resp := doJSON(t, "POST", api.URL+"/api/zingers", tokens.AccessToken, map[string]string{"body": "posted by an app"}, nil)
+		assert.Equal(t, 201, resp.StatusCode)
+		resp = doJSON(t, "PUT", api.URL+"/api/users", tokens.AccessToken, map[string]string{"email": "app@example.com", "password": "hijacked"}, nil)
+		assert.Equal(t, 403, resp.StatusCode)
+		resp = doJSON(t, "GET", api.URL+"/api/tokens", tokens.AccessToken, nil, nil)
+		assert.Equal(t, 403, resp.StatusCode, "apps can't manage credentials")
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		status, refreshed := client.post("/api/oauth/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}})
+		require.Equal(t, 200, status)
+		assert.Equal(t, auth.ScopeZingersWrite, refreshed.Scope)
+
+		resp := doJSON(t, "POST", api.URL+"/api/refresh", tokens.RefreshToken, nil, nil)
+		assert.Equal(t, 401, resp.StatusCode, "app tokens can't be refreshed as a first-party login")
+
+		tokens = refreshed
+	})
+
+	t.Run("Consent is remembered", func(t *testing.T) {
+		params := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {client.id},
+			"redirect_uri":          {client.redirectURI()},
+			"scope":                 {auth.ScopeZingersWrite},
+			"code_challenge":        {auth.PKCEChallenge(newPKCEVerifier(t))},
+			"code_challenge_method": {auth.PKCEMethodS256},
+		}
+		var consent struct {
+			ConsentRequired bool `json:"consent_required"`
+		}
+		resp := doJSON(t, "GET", api.URL+"/api/oauth/authorize?"+params.Encode(), userToken, nil, &consent)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.False(t, consent.ConsentRequired)
+
+		params.Set("scope", auth.ScopeZingersWrite+" "+auth.ScopeProfileWrite)
+		resp = doJSON(t, "GET", api.URL+"/api/oauth/authorize?"+params.Encode(), userToken, nil, &consent)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.True(t, consent.ConsentRequired)
+	})
+
+	t.Run("Unregistered redirect URI", func(t *testing.T) {
+		params := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {client.id},
+			"redirect_uri":          {"https://evil.example.com/callback"},
+			"scope":                 {auth.ScopeZingersWrite},
+			"code_challenge":        {auth.PKCEChallenge(newPKCEVerifier(t))},
+			"code_challenge_method": {auth.PKCEMethodS256},
+		}
+		resp := doJSON(t, "GET", api.URL+"/api/oauth/authorize?"+params.Encode(), userToken, nil, nil)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	t.Run("Code reuse revokes the grant", func(t *testing.T) {
+		status, body := client.post("/api/oauth/token", exchange)
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "invalid_grant", body.Error)
+
+		status, _ = client.post("/api/oauth/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}})
+		assert.Equal(t, 400, status)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		verifier := newPKCEVerifier(t)
+		got := client.authorize(userToken, auth.ScopeZingersWrite, "abc", auth.PKCEChallenge(verifier), true)
+		status, tokens := client.post("/api/oauth/token", url.Values{"grant_type": {"authorization_code"}, "code": {got.Get("code")}, "redirect_uri": {client.redirectURI()}, "code_verifier": {verifier}})
+		require.Equal(t, 200, status)
+
+		status, _ = client.post("/api/oauth/revoke", url.Values{"token": {tokens.RefreshToken}})
+		assert.Equal(t, 200, status)
+		status, body := client.post("/api/oauth/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}})
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "invalid_grant", body.Error)
+	})
+
+	t.Run("Wrong client secret", func(t *testing.T) {
+		impostor := *client
+		impostor.secret = "zzs_wrong"
+		status, body := impostor.post("/api/oauth/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"anything"}})
+		assert.Equal(t, 401, status)
+		assert.Equal(t, "invalid_client", body.Error)
+	})
+
+	t.Run("Profile scope leaves credentials alone", func(t *testing.T) {
+		verifier := newPKCEVerifier(t)
+		got := client.authorize(userToken, auth.ScopeProfileWrite, "profile", auth.PKCEChallenge(verifier), true)
+		status, tokens := client.post("/api/oauth/token", url.Values{"grant_type": {"authorization_code"}, "code": {got.Get("code")}, "redirect_uri": {client.redirectURI()}, "code_verifier": {verifier}})
+		require.Equal(t, 200, status)
+
+		resp := doJSON(t, "PATCH", api.URL+"/api/users/me", tokens.AccessToken, map[string]string{"bio": "set by an app"}, nil)
+		assert.Equal(t, 200, resp.StatusCode)
+		resp = doJSON(t, "PATCH", api.URL+"/api/users/me", tokens.AccessToken, map[string]string{"email": "app@example.com"}, nil)
+		assert.Equal(t, 400, resp.StatusCode)
+		resp = doJSON(t, "PUT", api.URL+"/api/users", tokens.AccessToken, map[string]string{"email": "app@example.com", "password": "hijacked", "current_password": "password"}, nil)
+		assert.Equal(t, 403, resp.StatusCode)
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor is the position of the last item of a page, in lists ordered by
+// creation time with the ID breaking ties. Clients get it as an opaque string.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (c pageCursor) String() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + "." + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parsePageCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return pageCursor{}, errInvalidCursor
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	cursorID, err := uuid.Parse(id)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	return pageCursor{CreatedAt: time.Unix(0, unixNano).UTC(), ID: cursorID}, nil
+}
+
+// pageRequest is the `cursor` and `limit` of a list request.
+type pageRequest struct {
+	After *pageCursor
+	Limit int
+}
+
+// parsePageRequest reads the page parameters of r, responding with 400 and
+// returning false when they are invalid.
+func parsePageRequest(w http.ResponseWriter, r *http.Request) (pageRequest, bool) {
+	page := pageRequest{Limit: defaultPageLimit}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageLimit {
+			respondWithError(w, 400, "limit must be between 1 and "+strconv.Itoa(maxPageLimit))
+			return pageRequest{}, false
+		}
+		page.Limit = n
+	}
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		cursor, err := parsePageCursor(s)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return pageRequest{}, false
+		}
+		page.After = &cursor
+	}
+	return page, true
+}
+
+// nextPageCursor trims items, fetched with one more than the page limit, to
+// the page and returns the cursor of the page after it, if there is one.
+func nextPageCursor[T any](items []T, limit int, position func(T) pageCursor) ([]T, *string) {
+	if len(items) <= limit {
+		return items, nil
+	}
+	items = items[:limit]
+	next := position(items[limit-1]).String()
+	return items, &next
+}
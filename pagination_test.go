@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageCursor(t *testing.T) {
+	cursor := pageCursor{CreatedAt: time.Date(2025, 3, 1, 12, 30, 0, 123456000, time.UTC), ID: uuid.New()}
+	got, err := parsePageCursor(cursor.String())
+	require.NoError(t, err)
+	assert.Equal(t, cursor, got)
+
+	for _, s := range []string{"", "not base64!", "bm9kb3Q", "MTIzLm5vdC1hLXV1aWQ"} {
+		_, err := parsePageCursor(s)
+		assert.ErrorIs(t, err, errInvalidCursor, s)
+	}
+}
+
+func TestNextPageCursor(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	position := func(id uuid.UUID) pageCursor { return pageCursor{CreatedAt: start, ID: id} }
+
+	page, next := nextPageCursor(ids, 3, position)
+	assert.Len(t, page, 3)
+	assert.Nil(t, next, "last page")
+
+	page, next = nextPageCursor(ids, 2, position)
+	assert.Equal(t, ids[:2], page)
+	require.NotNil(t, next)
+	assert.Equal(t, position(ids[1]).String(), *next)
+}
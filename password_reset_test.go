@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordForgotThrottle(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	cfg.reset_email_throttle = auth.LockoutPolicy{Threshold: 2, BaseDelay: time.Hour, MaxDelay: time.Hour, Window: time.Hour}
+	user, _ := createTestUser(t, cfg)
+	clearResetThrottles(t, cfg, user.Email, "127.0.0.1", "192.0.2.1")
+
+	for i := 0; i < 2; i++ {
+		resp := doJSON(t, "POST", api.URL+"/api/password/forgot", "", map[string]string{"email": user.Email}, nil)
+		require.Equal(t, 202, resp.StatusCode)
+	}
+	resp := doJSON(t, "POST", api.URL+"/api/password/forgot", "", map[string]string{"email": user.Email}, nil)
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	// Someone else hammering the address doesn't lock its owner out.
+	req := httptest.NewRequest("POST", "/api/password/forgot", strings.NewReader(`{"email": "`+user.Email+`"}`))
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	cfg.routes().ServeHTTP(rec, req)
+	assert.Equal(t, 202, rec.Code)
+}
+
+// clearResetThrottles forgets the password reset requests for email from
+// ips, and from ips at all, when the test ends.
+func clearResetThrottles(t *testing.T, cfg *apiConfig, email string, ips ...string) {
+	t.Cleanup(func() {
+		for _, ip := range ips {
+			cfg.dbq.ClearLoginThrottle(context.Background(), "reset-email:"+ip+":"+strings.ToLower(email))
+			cfg.dbq.ClearLoginThrottle(context.Background(), "reset-ip:"+ip)
+		}
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	user, _ := createTestUser(t, cfg)
+	clearResetThrottles(t, cfg, user.Email, "127.0.0.1")
+	session := startTestSession(t, cfg, user.ID)
+
+	resp := doJSON(t, "POST", api.URL+"/api/password/forgot", "", map[string]string{"email": user.Email}, nil)
+	require.Equal(t, 202, resp.StatusCode)
+	var token string
+	require.Eventually(t, func() bool {
+		token = mailedToken(t, cfg, user.Email)
+		return token != ""
+	}, 5*time.Second, 10*time.Millisecond, "the reset email is sent in the background")
+
+	t.Run("Expired", func(t *testing.T) {
+		require.NoError(t, cfg.sendPasswordReset(context.Background(), user.Email))
+		expired := mailedToken(t, cfg, user.Email)
+		_, err := cfg.db.Exec("UPDATE password_reset_tokens SET expires_at = $1 WHERE token_hash = $2", time.Now().UTC().Add(-time.Minute), auth.HashToken(expired))
+		require.NoError(t, err)
+		resp := doJSON(t, "POST", api.URL+"/api/password/reset", "", map[string]string{"token": expired, "password": "expired"}, nil)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	resp = doJSON(t, "POST", api.URL+"/api/password/reset", "", map[string]string{"token": token, "password": "superseded"}, nil)
+	assert.Equal(t, 400, resp.StatusCode, "a newer request replaces the link")
+
+	require.NoError(t, cfg.sendPasswordReset(context.Background(), user.Email))
+	token = mailedToken(t, cfg, user.Email)
+	resp = doJSON(t, "POST", api.URL+"/api/password/reset", "", map[string]string{"token": token, "password": "new password"}, nil)
+	require.Equal(t, 204, resp.StatusCode)
+
+	resp = doJSON(t, "POST", api.URL+"/api/password/reset", "", map[string]string{"token": token, "password": "again"}, nil)
+	assert.Equal(t, 400, resp.StatusCode, "links work once")
+
+	resp = doJSON(t, "POST", api.URL+"/api/refresh", session, nil, nil)
+	assert.Equal(t, 401, resp.StatusCode, "every session is logged out")
+	sessions, err := cfg.dbq.GetActiveSessionsByUser(context.Background(), database.GetActiveSessionsByUserParams{UserID: user.ID, ExpiresAt: time.Now().UTC()})
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	resp = doJSON(t, "POST", api.URL+"/api/login", "", map[string]string{"email": user.Email, "password": "new password"}, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestPasswordForgotInFlight(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	cfg.password_reset_sends = make(chan struct{}, 1)
+	cfg.password_reset_sends <- struct{}{}
+
+	resp := doJSON(t, "POST", api.URL+"/api/password/forgot", "", map[string]string{"email": "nobody@example.com"}, nil)
+	assert.Equal(t, 503, resp.StatusCode, "every send slot is taken")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/google/uuid"
+)
+
+// userMePatchHandler updates the profile fields of the caller's account that
+// are in the request and leaves the rest alone. Email and password are
+// changed with PUT /api/users, which delegated credentials can't reach.
+func (cfg *apiConfig) userMePatchHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Handle   *string `json:"handle"`
+		Email    *string `json:"email"`
+		Password *string `json:"password"`
+		profileFields
+	}
+	caller := principalFrom(r)
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "malformed request body")
+		return
+	}
+	if params.Email != nil || params.Password != nil {
+		respondWithError(w, 400, "email and password are changed with PUT /api/users")
+		return
+	}
+
+	update := database.UpdateUserProfileParams{ID: caller.UserID, UpdatedAt: time.Now().UTC()}
+	if params.Handle != nil {
+		if err := validateHandle(*params.Handle); err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		update.Handle = sql.NullString{String: *params.Handle, Valid: true}
+	}
+	if err := params.profileFields.validate(); err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	update.DisplayName = toNullString(params.DisplayName)
+	update.Bio = toNullString(params.Bio)
+	update.Website = toNullString(params.Website)
+	update.Location = toNullString(params.Location)
+
+	user, err := cfg.dbq.UpdateUserProfile(context.Background(), update)
+	if isHandleTaken(err) {
+		respondWithError(w, 409, errHandleTaken.Error())
+		return
+	}
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	type returnVals struct {
+		userResponse
+		Email         string    `json:"email"`
+		EmailVerified bool      `json:"email_verified"`
+		UpdatedAt     time.Time `json:"updated_at"`
+	}
+	respondWithJSON(w, 200, returnVals{userResponse: newUserResponse(user), Email: user.Email, EmailVerified: user.EmailVerifiedAt.Valid, UpdatedAt: user.UpdatedAt})
+}
+
+// toNullString turns an optional field of a request into a nullable query
+// argument.
+func toNullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+// zingerPatchHandler replaces the body of one of the caller's zingers, within
+// the edit window and up to the edit limit. The body it had before is kept
+// as a revision.
+func (cfg *apiConfig) zingerPatchHandler(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, "malformed request body")
+		return
+	}
+	if !cfg.checkZingerBody(w, params.Body, caller.IsPremium) {
+		return
+	}
+	params.Body = censorZinger(params.Body)
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	zinger, err := qtx.GetZingerByIdForUpdate(context.Background(), zingerID)
+	if err != nil || zinger.DeletedAt.Valid {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+	if zinger.UserID != caller.UserID {
+		handleErrorForbidden(w)
+		return
+	}
+	if zinger.RezingOfID.Valid {
+		respondWithError(w, 400, "rezings can't be edited")
+		return
+	}
+
+	if params.Body != zinger.Body {
+		now := time.Now().UTC()
+		if err := cfg.zinger_edits.check(zinger, caller.IsPremium, now); err != nil {
+			respondWithError(w, 403, err.Error())
+			return
+		}
+		// The old body was written when the zinger was posted, or when it
+		// was last edited.
+		writtenAt := zinger.CreatedAt
+		if zinger.EditCount > 0 {
+			writtenAt = zinger.UpdatedAt
+		}
+		err = qtx.CreateZingerRevision(context.Background(), database.CreateZingerRevisionParams{ZingerID: zinger.ID, Revision: zinger.EditCount, Body: zinger.Body, CreatedAt: writtenAt})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		zinger, err = qtx.EditZinger(context.Background(), database.EditZingerParams{Body: params.Body, UpdatedAt: now, ID: zinger.ID})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	respBody := newZingerResponse(zinger)
+	if err := cfg.completeZingerResponses(r, &respBody); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	respondWithJSON(w, 200, respBody)
+}
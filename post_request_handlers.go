@@ -10,14 +10,26 @@ import (
 	"errors"
 	"time"
 	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/bsuvonov/zingzing/internal/mailer"
 	"log"
 	"fmt"
-	"strconv"
 	"database/sql"
+	"strings"
+	"slices"
+	"net/url"
+	"crypto/subtle"
+	"io"
+	"github.com/bsuvonov/zingzing/internal/zingpay"
 )
 
 
 func (cfg *apiConfig) resetMetrics(w http.ResponseWriter, r *http.Request) {
+	action := newAdminAction(r, "reset_metrics")
+	action.Details = map[string]interface{}{"hits": cfg.fileserverHits.Load()}
+	if err := recordAdminAction(context.Background(), cfg.dbq, action); err != nil {
+		handleError(w, r, err)
+		return
+	}
 	cfg.fileserverHits.And(0)
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(200)
@@ -29,6 +41,7 @@ func (cfg *apiConfig) postUsersHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email string `json:"email"`
 		Password string `json:"password"`
+		Handle string `json:"handle"`
 	}
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
@@ -42,8 +55,14 @@ func (cfg *apiConfig) postUsersHandler(w http.ResponseWriter, r *http.Request) {
 		handleError(w, r, errors.New("password must be longer than 6 characters"))
 		return
 	}
+	if params.Handle != "" {
+		if err := validateHandle(params.Handle); err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
+	hashedPassword, err := cfg.hasher.Hash(params.Password)
 	if err != nil {
 		handleError(w, r, err)
 		return
@@ -54,21 +73,36 @@ func (cfg *apiConfig) postUsersHandler(w http.ResponseWriter, r *http.Request) {
 		CreatedAt time.Time `json:"created_at"`
 		UpdatedAt time.Time `json:"updated_at"`
 		Email     string    `json:"email"`
+		Handle    string    `json:"handle"`
 		IsPremium bool	`json:"is_premium"`
+		EmailVerified bool `json:"email_verified"`
 	}
 	respBody := returnVals{
 		ID: uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
 		Email: params.Email,
+		Handle: params.Handle,
 		IsPremium: false,
 	}
+	if respBody.Handle == "" {
+		respBody.Handle = defaultHandle(respBody.ID)
+	}
 
-	_, err = cfg.dbq.CreateUser(context.Background(), database.CreateUserParams{ID: respBody.ID, CreatedAt: respBody.CreatedAt, UpdatedAt: respBody.UpdatedAt, Email: respBody.Email, HashedPassword: hashedPassword})
+	user, err := cfg.dbq.CreateUser(context.Background(), database.CreateUserParams{ID: respBody.ID, CreatedAt: respBody.CreatedAt, UpdatedAt: respBody.UpdatedAt, Email: respBody.Email, HashedPassword: hashedPassword, Handle: respBody.Handle})
+	if isHandleTaken(err) {
+		respondWithError(w, 409, errHandleTaken.Error())
+		return
+	}
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
+	err = cfg.sendEmailVerification(context.Background(), user)
+	if err != nil {
+		// The account exists either way, the user can ask for another email.
+		log.Printf("Error sending verification email to user %s: %s", user.ID, err)
+	}
 
 	w.WriteHeader(201)
 	w.Header().Set("Content-Type", "application/json")
@@ -84,277 +118,1673 @@ func (cfg *apiConfig) postUsersHandler(w http.ResponseWriter, r *http.Request) {
 
 
 
-func (cfg *apiConfig) zingersPostHandler(w http.ResponseWriter, r *http.Request) {
+// emailVerificationLifetime is how long a verification link keeps working.
+const emailVerificationLifetime = time.Duration(24) * time.Hour
+
+// sendEmailVerification replaces any outstanding verification tokens for the
+// user with a fresh one and mails it to their current address.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, user database.User) error {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	err = cfg.dbq.InvalidateEmailVerificationTokens(ctx, database.InvalidateEmailVerificationTokensParams{UsedAt: sql.NullTime{Time: now, Valid: true}, UserID: user.ID})
+	if err != nil {
+		return err
+	}
+	err = cfg.dbq.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{TokenHash: auth.HashToken(token), UserID: user.ID, Email: user.Email, CreatedAt: now, ExpiresAt: now.Add(emailVerificationLifetime)})
+	if err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your ZingZing email address",
+		Body: fmt.Sprintf("Welcome to ZingZing!\n\nConfirm your email address by opening this link within the next 24 hours:\n\n%s/verify-email?token=%s\n\nIf you didn't sign up, you can ignore this email.\n",
+			cfg.base_url, token),
+	})
+}
+
+
+
+
+func (cfg *apiConfig) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Token string `json:"token"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := json.NewDecoder(r.Body).Decode(&params)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
 
-	// if len(params.Body) > 140 {
-	// 	handleError(w, r, err)
-	// 	return
-	// }
-
-	token, err := auth.GetBearerToken(r.Header)
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
 
-	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
-	// fmt.Println("BODY:" + params.Body)
-	// // fmt.Println("ERROR:" + err.Error())
-	// fmt.Println("TOKEN:" + token)
-	// fmt.Print("HEADER:")
-	// fmt.Println(r.Header)
+	now := time.Now().UTC()
+	token, err := qtx.UseEmailVerificationToken(context.Background(), database.UseEmailVerificationTokenParams{UsedAt: sql.NullTime{Time: now, Valid: true}, TokenHash: auth.HashToken(params.Token), ExpiresAt: now})
 	if err != nil {
-		if errors.Is(err, auth.ErrInvalidJWT) {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.WriteHeader(401)
-			w.Write([]byte("Unauthorized"))
-		} else {
-			handleError(w, r, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 400, "invalid or expired verification token")
+			return
 		}
+		handleError(w, r, err)
+		return
+	}
+	// The token only counts for the address it was sent to.
+	rows, err := qtx.MarkUserEmailVerified(context.Background(), database.MarkUserEmailVerifiedParams{EmailVerifiedAt: sql.NullTime{Time: now, Valid: true}, UpdatedAt: now, ID: token.UserID, Email: token.Email})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, 400, "invalid or expired verification token")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
 		return
 	}
-
-	params.Body = censorZinger(params.Body)
 
 	type returnVals struct {
-		Id uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body string `json:"body"`
-		UserId uuid.UUID `json:"user_id"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
 	}
+	respondWithJSON(w, 200, returnVals{Email: token.Email, EmailVerified: true})
+}
 
-	respBody := returnVals{
-		Id: uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Body: params.Body,
-		UserId: userID,
-	}
 
-	cfg.dbq.CreateZinger(context.Background(), database.CreateZingerParams{ID: respBody.Id, CreatedAt: respBody.CreatedAt, UpdatedAt: respBody.UpdatedAt, Body: respBody.Body, UserID: respBody.UserId})
 
-	dat, err := json.Marshal(respBody)
-	if err!=nil {
-		log.Printf("Error in converting response body to json: %s", err)
+
+func (cfg *apiConfig) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	user, err := cfg.dbq.GetUserByID(context.Background(), userID)
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
-
-	w.WriteHeader(201)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(dat)
+	if user.EmailVerifiedAt.Valid {
+		respondWithError(w, 409, "email address is already verified")
+		return
+	}
+	err = cfg.sendEmailVerification(context.Background(), user)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(204)
 }
 
 
 
 
-func (cfg *apiConfig) userLoginHandler(w http.ResponseWriter, r *http.Request) {
+// passwordResetLifetime is how long a password reset link keeps working.
+const passwordResetLifetime = time.Duration(1) * time.Hour
+
+// passwordResetSendTimeout bounds how long sending one reset email may take.
+const passwordResetSendTimeout = 30 * time.Second
+
+func (cfg *apiConfig) passwordForgotHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email string `json:"email"`
-		Password string `json:"password"`
 	}
-
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := json.NewDecoder(r.Body).Decode(&params)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
 
-	user, err := cfg.dbq.GetUserByEmail(context.Background(), params.Email)
-	if err != nil && err.Error() == "sql: no rows in result set" {
-		handleUserLoginError(w, r)
+	// Requests are counted per client IP and email, and per client IP,
+	// whether or not the account exists, so the limits say nothing about
+	// which emails are registered. An email isn't limited on its own, or
+	// anyone could keep its owner from resetting their password.
+	throttles := []loginThrottleKey{
+		{Key: "reset-email:" + clientIP(r) + ":" + strings.ToLower(params.Email), Policy: cfg.reset_email_throttle},
+		{Key: "reset-ip:" + clientIP(r), Policy: cfg.reset_ip_throttle},
+	}
+	if cfg.checkLoginLockout(w, r, throttles, "too many password reset requests, try again later") {
 		return
 	}
+	select {
+	case cfg.password_reset_sends <- struct{}{}:
+	default:
+		respondWithError(w, 503, "too many password resets in progress, try again later")
+		return
+	}
+	cfg.recordLoginFailure(throttles)
+
+	// Everything that depends on whether the account exists happens after
+	// the response is written, so neither its content nor its timing tells
+	// the caller which emails are registered.
+	go func() {
+		defer func() { <-cfg.password_reset_sends }()
+		ctx, cancel := context.WithTimeout(context.Background(), passwordResetSendTimeout)
+		defer cancel()
+		err := cfg.sendPasswordReset(ctx, params.Email)
+		if err != nil {
+			log.Printf("Error sending password reset email: %s", err)
+		}
+	}()
+
+	type returnVals struct {
+		Message string `json:"message"`
+	}
+	respondWithJSON(w, 202, returnVals{Message: "If an account exists for that email, a password reset link is on its way."})
+}
 
-	err = auth.CheckPasswordHash(user.HashedPassword, params.Password)
+
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := cfg.dbq.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
 	if err != nil {
-		handleUserLoginError(w, r)
-		return
+		return err
 	}
+	token, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	err = cfg.dbq.InvalidatePasswordResetTokens(ctx, database.InvalidatePasswordResetTokensParams{UsedAt: sql.NullTime{Time: now, Valid: true}, UserID: user.ID})
+	if err != nil {
+		return err
+	}
+	err = cfg.dbq.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{TokenHash: auth.HashToken(token), UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(passwordResetLifetime)})
+	if err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your ZingZing password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your ZingZing account.\n\nChoose a new password by opening this link within the next hour:\n\n%s/reset-password?token=%s\n\nIf it wasn't you, you can ignore this email and your password stays the same.\n",
+			cfg.base_url, token),
+	})
+}
+
 
-	token, err := auth.MakeJWT(user.ID, cfg.jwt_secret, time.Duration(3600)*time.Second)
+
+
+func (cfg *apiConfig) passwordResetHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if len(params.Password) < 1 {
+		respondWithError(w, 400, "password must not be empty")
+		return
+	}
+	hashedPassword, err := cfg.hasher.Hash(params.Password)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
 
-	type returnVals struct {
-		Id uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Email string `json:"email"`
-		Token string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-		IsPremium bool `json:"is_premium"`
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
 
-	refreshToken, err := auth.MakeRefreshToken()
+	now := time.Now().UTC()
+	token, err := qtx.UsePasswordResetToken(context.Background(), database.UsePasswordResetTokenParams{UsedAt: sql.NullTime{Time: now, Valid: true}, TokenHash: auth.HashToken(params.Token), ExpiresAt: now})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 400, "invalid or expired password reset token")
+			return
+		}
 		handleError(w, r, err)
 		return
 	}
-	_, err = cfg.dbq.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{Token: refreshToken, CreatedAt: time.Now(), UpdatedAt: time.Now(), UserID: user.ID, ExpiresAt: time.Now().Add(time.Duration(1440)*time.Hour)})
-	if err!= nil {
+	err = qtx.UpdateUserPassword(context.Background(), database.UpdateUserPasswordParams{HashedPassword: hashedPassword, UpdatedAt: now, ID: token.UserID})
+	if err != nil {
 		handleError(w, r, err)
 		return
 	}
-
-	respBody := returnVals{Id: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, Token: token, RefreshToken: refreshToken, IsPremium: user.IsPremium}
-	dat, err := json.Marshal(respBody)
+	err = qtx.InvalidatePasswordResetTokens(context.Background(), database.InvalidatePasswordResetTokensParams{UsedAt: sql.NullTime{Time: now, Valid: true}, UserID: token.UserID})
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
-	w.WriteHeader(200)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(dat)
+	// Whoever knew the old password may still hold a session.
+	err = qtx.RevokeAllRefreshTokensForUser(context.Background(), database.RevokeAllRefreshTokensForUserParams{UpdatedAt: now, RevokedAt: sql.NullTime{Time: now, Valid: true}, UserID: token.UserID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(204)
 }
 
 
-func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
-	time.Sleep(1000 * time.Millisecond)
-	// expects refresh token in request header
-	refreshToken, err := auth.GetBearerToken(r.Header)
+
+
+func (cfg *apiConfig) zingersPostHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body        string     `json:"body"`
+		InReplyToID *uuid.UUID `json:"in_reply_to_id"`
+		QuoteOfID   *uuid.UUID `json:"quote_of_id"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
-	token, err := cfg.dbq.GetRefreshTokenByToken(context.Background(), refreshToken)
+
+	caller := principalFrom(r)
+	userID := caller.UserID
+
+	if !cfg.checkZingerBody(w, params.Body, caller.IsPremium) {
+		return
+	}
+
+	params.Body = censorZinger(params.Body)
+
+	now := time.Now().UTC()
+	create := database.CreateZingerParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: params.Body, UserID: userID}
+	create.ConversationID = create.ID
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.WriteHeader(401)
-			w.Write([]byte("Unauthorized"))
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	if params.InReplyToID != nil {
+		// Counting the reply first locks the parent, so it can't be deleted
+		// outright while the reply is added.
+		rows, err := qtx.IncrementReplyCount(context.Background(), *params.InReplyToID)
+		if err != nil {
+			handleError(w, r, err)
 			return
 		}
-		handleError(w, r, err)
+		if rows == 0 {
+			respondWithError(w, 400, "in_reply_to_id is not a zinger")
+			return
+		}
+		parent, err := qtx.GetZingerById(context.Background(), *params.InReplyToID)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		create.InReplyToID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		create.ConversationID = parent.ConversationID
 	}
-	fmt.Println("TOKEN:")
-	fmt.Print("CREATED AT:")
-	fmt.Println(token.CreatedAt.UTC())
-	fmt.Printf("UPDATED AT:")
-	fmt.Println(token.UpdatedAt.UTC())
-	fmt.Print("EXPIRES AT:")
-	fmt.Println(token.ExpiresAt.UTC())
-	fmt.Print("TOKEN:")
-	fmt.Println(token.Token)
-	if token.RevokedAt.Valid {
-		fmt.Print("REVOKED AT:")
-		fmt.Println(token.RevokedAt.Time.UTC())
+	if params.QuoteOfID != nil {
+		quoted, err := countShare(qtx, *params.QuoteOfID, qtx.IncrementQuoteCount)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 400, "quote_of_id is not a zinger")
+			return
+		}
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		create.QuoteOfID = uuid.NullUUID{UUID: quoted, Valid: true}
 	}
-	fmt.Println("TIME NOW:" + time.Now().UTC().String())
-	fmt.Println("ARE WE RETURNING 401:" + strconv.FormatBool(token.RevokedAt.Time.UTC().Before(time.Now().UTC())))
-	if (token.RevokedAt.Valid && token.RevokedAt.Time.UTC().Before(time.Now().UTC())) || token.ExpiresAt.UTC().Before(time.Now().UTC()) {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(401)
-		w.Write([]byte("Unauthorized"))
+
+	zinger, err := qtx.CreateZinger(context.Background(), create)
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
-
-	user, err := cfg.dbq.GetUserByRefreshToken(context.Background(), refreshToken)
-	if err!=nil {
+	// Into the author's and their followers' home timelines, unless they
+	// have too many followers to copy it to all of them. The zinger
+	// remembers which it was.
+	err = qtx.FanOutZinger(context.Background(), database.FanOutZingerParams{FanoutThreshold: int32(cfg.timeline_fanout_threshold), ZingerID: zinger.ID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
 		handleError(w, r, err)
 		return
 	}
 
-	tokenJWT, err := auth.MakeJWT(user.ID, cfg.jwt_secret, time.Duration(1)*time.Hour)
-	if err != nil {
+	respBody := newZingerResponse(zinger)
+	if err := cfg.completeZingerResponses(r, &respBody); err != nil {
 		handleError(w, r, err)
 		return
 	}
+	respondWithJSON(w, 201, respBody)
+}
 
-	type returnVals struct {
-		Token string `json:"token"`
+// countShare counts a rezing or quote of the zinger with the given ID, with
+// increment, and returns the ID of the zinger that is actually shared:
+// sharing a rezing shares what it rezinged. It returns sql.ErrNoRows when
+// there is no such zinger, or it was deleted.
+func countShare(qtx *database.Queries, zingerID uuid.UUID, increment func(context.Context, uuid.UUID) (int64, error)) (uuid.UUID, error) {
+	zinger, err := qtx.GetZingerById(context.Background(), zingerID)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if zinger.RezingOfID.Valid {
+		zingerID = zinger.RezingOfID.UUID
+	}
+	// Counting first locks the shared zinger, so it can't be deleted while
+	// it is shared.
+	rows, err := increment(context.Background(), zingerID)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if rows == 0 {
+		return uuid.UUID{}, sql.ErrNoRows
 	}
+	return zingerID, nil
+}
 
-	respBody := returnVals{Token: tokenJWT}
+// rezingPostHandler shares the zinger in the path with the caller's
+// followers. A zinger can be rezinged once by each user.
+func (cfg *apiConfig) rezingPostHandler(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
 
-	dat, err := json.Marshal(respBody)
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
-	w.Write(dat)
-}
-
-
-func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err!=nil {
+	rezingOfID, err := countShare(qtx, zingerID, qtx.IncrementRezingCount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
 		handleError(w, r, err)
+		return
 	}
-	fmt.Println("============")
-	fmt.Println("REVOKING:" + token)
-	err = cfg.dbq.RevokeRefreshToken(context.Background(), database.RevokeRefreshTokenParams{UpdatedAt: time.Now().UTC(), RevokedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true}, Token: token})
+	rezing, err := qtx.CreateRezing(context.Background(), database.CreateRezingParams{ID: uuid.New(), CreatedAt: time.Now().UTC(), UserID: caller.UserID, RezingOfID: uuid.NullUUID{UUID: rezingOfID, Valid: true}})
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 409, "you already rezinged this zinger")
+			return
+		}
 		handleError(w, r, err)
+		return
 	}
-	tokenDb, _ := cfg.dbq.GetRefreshTokenByToken(context.Background(), token)
-	fmt.Println("TOKEN:")
-	fmt.Print("CREATED AT:")
-	fmt.Println(tokenDb.CreatedAt)
-	fmt.Printf("UPDATED AT:")
-	fmt.Println(tokenDb.UpdatedAt)
-	fmt.Print("EXPIRES AT:")
-	fmt.Println(tokenDb.ExpiresAt)
-	fmt.Print("TOKEN:")
-	fmt.Println(tokenDb.Token)
-	if tokenDb.RevokedAt.Valid {
-		fmt.Print("REVOKED AT:")
-		fmt.Println(tokenDb.RevokedAt.Time)
+	err = qtx.FanOutZinger(context.Background(), database.FanOutZingerParams{FanoutThreshold: int32(cfg.timeline_fanout_threshold), ZingerID: rezing.ID})
+	if err != nil {
+		handleError(w, r, err)
+		return
 	}
-	fmt.Println("============")
-	w.WriteHeader(204)
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	respBody := newZingerResponse(rezing)
+	if err := cfg.completeZingerResponses(r, &respBody); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	respondWithJSON(w, 201, respBody)
 }
 
 
 
-func (cfg *apiConfig) webhookHandler(w http.ResponseWriter, r *http.Request) {
-	apiKey, err := auth.GetAPIKey(r.Header)
+
+// maxPersonalAccessTokenLifetime caps how far in the future a personal access
+// token may expire.
+const maxPersonalAccessTokenLifetime = time.Duration(366*24) * time.Hour
+
+func (cfg *apiConfig) tokensPostHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
 	if err != nil {
-		handleErrorUnauthorized(w)
+		handleError(w, r, err)
 		return
 	}
-	if cfg.zingpay_key != apiKey {
-		handleErrorUnauthorized(w)
+
+	// The route is for sessions only, tokens can't mint more tokens.
+	userID := principalFrom(r).UserID
+
+	now := time.Now().UTC()
+	if strings.TrimSpace(params.Name) == "" {
+		respondWithError(w, 400, "name is required")
 		return
 	}
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
+	if err := auth.ValidateScopes(params.Scopes); err != nil {
+		respondWithError(w, 400, err.Error())
+		return
 	}
-	params := parameters{}
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&params)
+	if !params.ExpiresAt.After(now) || params.ExpiresAt.Sub(now) > maxPersonalAccessTokenLifetime {
+		respondWithError(w, 400, "expires_at must be in the future and at most a year away")
+		return
+	}
+
+	token, err := auth.MakePersonalAccessToken()
 	if err != nil {
 		handleError(w, r, err)
-	}
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(204)
 		return
 	}
-	err = cfg.dbq.UpgradeToPremium(context.Background(), uuid.MustParse(params.Data.UserID))
+	pat, err := cfg.dbq.CreatePersonalAccessToken(context.Background(), database.CreatePersonalAccessTokenParams{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      strings.TrimSpace(params.Name),
+		TokenHash: auth.HashToken(token),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(params.Scopes))),
+		CreatedAt: now,
+		ExpiresAt: params.ExpiresAt.UTC(),
+	})
 	if err != nil {
-		handleErrorNotFound(w)
+		handleError(w, r, err)
 		return
 	}
-	w.WriteHeader(204)
+
+	respBody := newPersonalAccessTokenResponse(pat)
+	respBody.Token = token
+	respondWithJSON(w, 201, respBody)
+}
+
+
+const tooManyLoginAttemptsMessage = "too many failed login attempts, try again later"
+
+// loginThrottleKey is one counter of failed logins, such as all attempts
+// against an account or all attempts from an IP address. Password reset
+// requests are counted the same way under keys of their own.
+type loginThrottleKey struct {
+	Key    string
+	Policy auth.LockoutPolicy
 }
 
+// checkLoginLockout writes a 429 with msg and returns true while any of the
+// counters is locked out.
+func (cfg *apiConfig) checkLoginLockout(w http.ResponseWriter, r *http.Request, keys []loginThrottleKey, msg string) bool {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.Key
+	}
+	throttles, err := cfg.dbq.GetLoginThrottles(context.Background(), names)
+	if err != nil {
+		handleError(w, r, err)
+		return true
+	}
+	var retryAfter time.Duration
+	now := time.Now().UTC()
+	for _, throttle := range throttles {
+		if throttle.LockedUntil.Valid && throttle.LockedUntil.Time.After(now) {
+			retryAfter = max(retryAfter, throttle.LockedUntil.Time.Sub(now))
+		}
+	}
+	if retryAfter > 0 {
+		handleErrorTooManyRequests(w, msg, retryAfter)
+		return true
+	}
+	return false
+}
+
+// recordLoginFailure counts a failed attempt against every key and locks
+// those that crossed their policy's threshold. The counters live in Postgres
+// so that every server instance sees the same ones.
+func (cfg *apiConfig) recordLoginFailure(keys []loginThrottleKey) {
+	now := time.Now().UTC()
+	for _, key := range keys {
+		throttle, err := cfg.dbq.RecordLoginFailure(context.Background(), database.RecordLoginFailureParams{Key: key.Key, Now: now, WindowStart: now.Add(-key.Policy.Window)})
+		if err != nil {
+			log.Printf("Error recording failed login for %s: %s", key.Key, err)
+			continue
+		}
+		delay := key.Policy.Delay(int(throttle.Failures))
+		if delay == 0 {
+			continue
+		}
+		err = cfg.dbq.SetLoginLockout(context.Background(), database.SetLoginLockoutParams{LockedUntil: sql.NullTime{Time: now.Add(delay), Valid: true}, Key: key.Key})
+		if err != nil {
+			log.Printf("Error locking out %s: %s", key.Key, err)
+		}
+	}
+}
+
+// clearLoginFailures resets a counter after a successful attempt. Only the
+// account counter is cleared: one good login from an address shouldn't wipe
+// out its failures against other accounts.
+func (cfg *apiConfig) clearLoginFailures(key loginThrottleKey) {
+	err := cfg.dbq.ClearLoginThrottle(context.Background(), key.Key)
+	if err != nil {
+		log.Printf("Error clearing failed logins for %s: %s", key.Key, err)
+	}
+}
+
+
+// upgradePasswordHash replaces a user's stored hash after a successful login,
+// unless the password was changed since oldHash was read. Failures are only
+// logged, the old hash still works.
+func (cfg *apiConfig) upgradePasswordHash(userID uuid.UUID, oldHash, password string) {
+	hashedPassword, err := cfg.hasher.Hash(password)
+	if err == nil {
+		err = cfg.dbq.UpdateUserPasswordIfHash(context.Background(), database.UpdateUserPasswordIfHashParams{NewHash: hashedPassword, UpdatedAt: time.Now().UTC(), ID: userID, OldHash: oldHash})
+	}
+	if err != nil {
+		log.Printf("Error upgrading password hash for user %s: %s", userID, err)
+	}
+}
+
+
+// refreshTokenLifetime is how long a single refresh token stays valid. Every
+// successful refresh replaces the token, so an active session slides forward.
+const refreshTokenLifetime = time.Duration(1440) * time.Hour
+
+// refreshSession describes the device a refresh token family was issued to.
+// Sessions granted to an OAuth client record the client and its scopes.
+type refreshSession struct {
+	FamilyID  uuid.UUID
+	StartedAt time.Time
+	UserAgent string
+	IPAddress string
+	ClientID  uuid.NullUUID
+	Scopes    []string
+}
+
+// newRefreshSession starts a session for the client making the request.
+func newRefreshSession(r *http.Request) refreshSession {
+	return refreshSession{FamilyID: uuid.New(), StartedAt: time.Now().UTC(), UserAgent: r.UserAgent(), IPAddress: clientIP(r)}
+}
+
+// issueRefreshToken stores a new refresh token for the session. parent is the
+// token it replaces, or empty for the first token of a login.
+func issueRefreshToken(ctx context.Context, q *database.Queries, userID uuid.UUID, session refreshSession, parent string) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	scopes := session.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	now := time.Now().UTC()
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:            refreshToken,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		UserID:           userID,
+		ExpiresAt:        now.Add(refreshTokenLifetime),
+		FamilyID:         session.FamilyID,
+		ParentToken:      sql.NullString{String: parent, Valid: parent != ""},
+		SessionStartedAt: session.StartedAt,
+		LastUsedAt:       now,
+		UserAgent:        session.UserAgent,
+		IpAddress:        session.IPAddress,
+		ClientID:         session.ClientID,
+		Scopes:           scopes,
+	})
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+
+func (cfg *apiConfig) userLoginHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	throttles := []loginThrottleKey{
+		{Key: "email:" + strings.ToLower(params.Email), Policy: cfg.account_lockout},
+		{Key: "ip:" + clientIP(r), Policy: cfg.ip_lockout},
+	}
+	if cfg.checkLoginLockout(w, r, throttles, tooManyLoginAttemptsMessage) {
+		return
+	}
+
+	user, err := cfg.dbq.GetUserByEmail(context.Background(), params.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		handleError(w, r, err)
+		return
+	}
+	if err != nil {
+		cfg.recordLoginFailure(throttles)
+		handleUserLoginError(w, r)
+		return
+	}
+
+	needsRehash, err := cfg.hasher.Check(user.HashedPassword, params.Password)
+	if err != nil {
+		cfg.recordLoginFailure(throttles)
+		handleUserLoginError(w, r)
+		return
+	}
+	cfg.clearLoginFailures(throttles[0])
+	if needsRehash {
+		// The password is known to be right, so quietly move the stored hash
+		// onto the current algorithm and parameters.
+		cfg.upgradePasswordHash(user.ID, user.HashedPassword, params.Password)
+	}
+
+	if user.TotpEnabledAt.Valid {
+		challengeToken, err := cfg.jwt_keys.MakeChallengeJWT(user.ID, twoFactorChallengeLifetime)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		type returnVals struct {
+			TwoFactorRequired bool `json:"two_factor_required"`
+			ChallengeToken string `json:"challenge_token"`
+		}
+		respondWithJSON(w, 200, returnVals{TwoFactorRequired: true, ChallengeToken: challengeToken})
+		return
+	}
+
+	cfg.respondWithLogin(w, r, user)
+}
+
+
+// twoFactorChallengeLifetime is how long a user has to enter their second
+// factor after giving the right password.
+const twoFactorChallengeLifetime = time.Duration(5) * time.Minute
+
+func (cfg *apiConfig) twoFactorLoginHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code string `json:"code"`
+	}
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	userID, err := cfg.jwt_keys.ValidateChallengeJWT(params.ChallengeToken)
+	if err != nil {
+		handleErrorUnauthorized(w)
+		return
+	}
+	user, err := cfg.dbq.GetUserByID(context.Background(), userID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		handleErrorUnauthorized(w)
+		return
+	}
+
+	throttles := []loginThrottleKey{
+		{Key: "2fa:" + user.ID.String(), Policy: cfg.account_lockout},
+		{Key: "ip:" + clientIP(r), Policy: cfg.ip_lockout},
+	}
+	if cfg.checkLoginLockout(w, r, throttles, tooManyLoginAttemptsMessage) {
+		return
+	}
+
+	ok, err := cfg.checkSecondFactor(user, params.Code)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if !ok {
+		cfg.recordLoginFailure(throttles)
+		respondWithError(w, 401, "invalid two-factor code")
+		return
+	}
+	cfg.clearLoginFailures(throttles[0])
+
+	cfg.respondWithLogin(w, r, user)
+}
+
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code, and uses it up.
+func (cfg *apiConfig) checkSecondFactor(user database.User, code string) (bool, error) {
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, strings.TrimSpace(code), time.Now(), user.TotpLastStep)
+	if ok {
+		// Only one login per code, even when two arrive at the same time.
+		rows, err := cfg.dbq.UpdateUserTOTPLastStep(context.Background(), database.UpdateUserTOTPLastStepParams{TotpLastStep: step, ID: user.ID})
+		return rows == 1, err
+	}
+	rows, err := cfg.dbq.UseRecoveryCode(context.Background(), database.UseRecoveryCodeParams{UsedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true}, UserID: user.ID, CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code))})
+	return rows == 1, err
+}
+
+
+// respondWithLogin finishes a successful login by issuing an access token and
+// a refresh token for a new session.
+func (cfg *apiConfig) respondWithLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	token, err := cfg.jwt_keys.MakeJWTWithRole(user.ID, user.Role, time.Duration(3600)*time.Second)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	type returnVals struct {
+		Id uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Email string `json:"email"`
+		Token string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+		IsPremium bool `json:"is_premium"`
+		EmailVerified bool `json:"email_verified"`
+	}
+
+	refreshToken, err := issueRefreshToken(context.Background(), cfg.dbq, user.ID, newRefreshSession(r), "")
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	isPremium, err := cfg.dbq.IsUserPremium(context.Background(), database.IsUserPremiumParams{UserID: user.ID, Now: time.Now().UTC()})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	respBody := returnVals{Id: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, Email: user.Email, Token: token, RefreshToken: refreshToken, IsPremium: isPremium, EmailVerified: user.EmailVerifiedAt.Valid}
+	respondWithJSON(w, 200, respBody)
+}
+
+
+func (cfg *apiConfig) twoFactorEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	userID := principalFrom(r).UserID
+	user, err := cfg.dbq.GetUserByID(context.Background(), userID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, 409, "two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	err = cfg.dbq.SetUserTOTPSecret(context.Background(), database.SetUserTOTPSecretParams{TotpSecret: sql.NullString{String: secret, Valid: true}, UpdatedAt: time.Now().UTC(), ID: user.ID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	type returnVals struct {
+		Secret string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+	respondWithJSON(w, 200, returnVals{Secret: secret, OtpauthURI: auth.TOTPURI("ZingZing", user.Email, secret)})
+}
+
+
+// recoveryCodeCount is how many recovery codes a user gets when enabling 2FA.
+const recoveryCodeCount = 10
+
+func (cfg *apiConfig) twoFactorConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	userID := principalFrom(r).UserID
+	user, err := cfg.dbq.GetUserByID(context.Background(), userID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, 409, "two-factor authentication is already enabled")
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondWithError(w, 400, "start two-factor enrollment first")
+		return
+	}
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, strings.TrimSpace(params.Code), time.Now(), user.TotpLastStep)
+	if !ok {
+		respondWithError(w, 400, "invalid two-factor code")
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	now := time.Now().UTC()
+	err = qtx.EnableUserTOTP(context.Background(), database.EnableUserTOTPParams{TotpEnabledAt: sql.NullTime{Time: now, Valid: true}, TotpLastStep: step, UpdatedAt: now, ID: user.ID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	err = qtx.DeleteRecoveryCodes(context.Background(), user.ID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	for _, code := range codes {
+		err = qtx.CreateRecoveryCode(context.Background(), database.CreateRecoveryCodeParams{ID: uuid.New(), UserID: user.ID, CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)), CreatedAt: now})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	type returnVals struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	respondWithJSON(w, 200, returnVals{RecoveryCodes: codes})
+}
+
+
+func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	// expects refresh token in request header
+	refreshToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		handleErrorUnauthorized(w)
+		return
+	}
+	token, err := cfg.dbq.GetRefreshTokenByToken(context.Background(), refreshToken)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			handleErrorUnauthorized(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+
+	// Tokens granted to OAuth clients are refreshed at /api/oauth/token.
+	if token.ClientID.Valid || token.RevokedAt.Valid || token.ExpiresAt.UTC().Before(time.Now().UTC()) {
+		handleErrorUnauthorized(w)
+		return
+	}
+	newRefreshToken, err := cfg.rotateRefreshToken(r, token)
+	if err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			handleErrorUnauthorized(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+
+	// The role may have changed since the last token was issued.
+	user, err := cfg.dbq.GetUserByID(context.Background(), token.UserID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	tokenJWT, err := cfg.jwt_keys.MakeJWTWithRole(user.ID, user.Role, time.Duration(1)*time.Hour)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	type returnVals struct {
+		Token string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	respBody := returnVals{Token: tokenJWT, RefreshToken: newRefreshToken}
+
+	dat, err := json.Marshal(respBody)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+
+// errRefreshTokenReused means a refresh token came back after it had been
+// rotated. The whole token family is revoked by the time it is returned.
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// rotateRefreshToken uses up token and issues its successor in the same
+// family. The caller checks that token is still valid.
+func (cfg *apiConfig) rotateRefreshToken(r *http.Request, token database.RefreshToken) (string, error) {
+	if token.UsedAt.Valid {
+		// A rotated token came back: someone holds a copy of it, so nothing
+		// issued from this login can be trusted any more.
+		return "", cfg.revokeRefreshTokenFamily(token.FamilyID)
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	now := time.Now().UTC()
+	rows, err := qtx.MarkRefreshTokenUsed(context.Background(), database.MarkRefreshTokenUsedParams{UpdatedAt: now, UsedAt: sql.NullTime{Time: now, Valid: true}, Token: token.Token})
+	if err != nil {
+		return "", err
+	}
+	if rows == 0 {
+		// Lost the race against another refresh with the same token.
+		tx.Rollback()
+		return "", cfg.revokeRefreshTokenFamily(token.FamilyID)
+	}
+
+	session := refreshSession{FamilyID: token.FamilyID, StartedAt: token.SessionStartedAt, UserAgent: r.UserAgent(), IPAddress: clientIP(r), ClientID: token.ClientID, Scopes: token.Scopes}
+	newRefreshToken, err := issueRefreshToken(context.Background(), qtx, token.UserID, session, token.Token)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return newRefreshToken, nil
+}
+
+// revokeRefreshTokenFamily answers a refresh token reuse by revoking every
+// token descended from the same login. It returns errRefreshTokenReused once
+// that is done.
+func (cfg *apiConfig) revokeRefreshTokenFamily(familyID uuid.UUID) error {
+	now := time.Now().UTC()
+	err := cfg.dbq.RevokeRefreshTokenFamily(context.Background(), database.RevokeRefreshTokenFamilyParams{UpdatedAt: now, RevokedAt: sql.NullTime{Time: now, Valid: true}, FamilyID: familyID})
+	if err != nil {
+		return err
+	}
+	log.Printf("Refresh token reuse detected, revoked token family %s", familyID)
+	return errRefreshTokenReused
+}
+
+
+func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		handleErrorUnauthorized(w)
+		return
+	}
+	now := time.Now().UTC()
+	err = cfg.dbq.RevokeRefreshToken(context.Background(), database.RevokeRefreshTokenParams{UpdatedAt: now, RevokedAt: sql.NullTime{Time: now, Valid: true}, Token: token})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+
+
+// maxWebhookBodySize bounds what we read of a webhook delivery.
+const maxWebhookBodySize = 1 << 20
+
+// webhookHandler receives ZingPay events. Deliveries must be signed with one
+// of the webhook secrets and recent. They are stored in the webhook inbox as
+// received and acknowledged right away; runWebhookWorker processes them.
+// Repeated deliveries of an event ID are acknowledged without storing them
+// again.
+func (cfg *apiConfig) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		respondWithError(w, 400, "could not read body")
+		return
+	}
+	err = zingpay.VerifySignature(r.Header.Get(zingpay.SignatureHeader), body, cfg.zingpay_webhook_secrets, cfg.zingpay_webhook_tolerance, time.Now())
+	if err != nil {
+		log.Printf("Rejected ZingPay webhook: %s", err)
+		handleErrorUnauthorized(w)
+		return
+	}
+
+	event := zingpay.Event{}
+	err = json.Unmarshal(body, &event)
+	if err != nil || event.ID == "" {
+		respondWithError(w, 400, "malformed event")
+		return
+	}
+
+	// A duplicate was stored already, ZingPay just didn't get our answer.
+	_, err = cfg.dbq.RecordWebhookEvent(context.Background(), database.RecordWebhookEventParams{ID: event.ID, EventType: event.Type, ReceivedAt: time.Now().UTC(), Payload: string(body)})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	cfg.notifyWebhookWorker()
+	w.WriteHeader(204)
+}
+
+
+
+func (cfg *apiConfig) oauthClientsPostHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	caller := principalFrom(r)
+	userID := caller.UserID
+
+	if strings.TrimSpace(params.Name) == "" {
+		respondWithError(w, 400, "name is required")
+		return
+	}
+	if len(params.RedirectURIs) == 0 {
+		respondWithError(w, 400, "at least one redirect URI is required")
+		return
+	}
+	for _, uri := range params.RedirectURIs {
+		if err := auth.ValidateRedirectURI(uri); err != nil {
+			respondWithError(w, 400, fmt.Sprintf("invalid redirect URI %q: %s", uri, err))
+			return
+		}
+	}
+
+	// Public clients, such as mobile and single-page apps, can't keep a
+	// secret and rely on PKCE alone.
+	var secret string
+	var secretHash sql.NullString
+	if params.Confidential {
+		secret, err = auth.MakeClientSecret()
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.dbq.CreateOAuthClient(context.Background(), database.CreateOAuthClientParams{
+		ID:           uuid.New(),
+		OwnerID:      userID,
+		Name:         strings.TrimSpace(params.Name),
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+		CreatedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	respBody := newOAuthClientResponse(client)
+	respBody.ClientSecret = secret
+	respondWithJSON(w, 201, respBody)
+}
+
+
+// oauthCodeLifetime is how long an authorization code can be exchanged.
+const oauthCodeLifetime = time.Duration(10) * time.Minute
+
+// oauthAccessTokenLifetime matches the access tokens of a first-party login.
+const oauthAccessTokenLifetime = time.Duration(1) * time.Hour
+
+// oauthAuthorizePostHandler records the user's answer to an authorization
+// request. The frontend sends the user on to the returned redirect_to, which
+// carries either an authorization code or an access_denied error.
+func (cfg *apiConfig) oauthAuthorizePostHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		authorizeRequest
+		Approve bool `json:"approve"`
+	}
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	userID := principalFrom(r).UserID
+	client, scopes, ok := cfg.checkAuthorizeRequest(w, r, params.authorizeRequest)
+	if !ok {
+		return
+	}
+
+	redirect, err := url.Parse(params.RedirectURI)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	query := redirect.Query()
+	if params.State != "" {
+		query.Set("state", params.State)
+	}
+
+	if !params.Approve {
+		query.Set("error", "access_denied")
+	} else {
+		code, err := auth.MakeToken()
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		now := time.Now().UTC()
+
+		// Remember the consent so the app can ask again without bothering
+		// the user, unless it asks for more.
+		consented := scopes
+		consent, err := cfg.dbq.GetOAuthConsent(context.Background(), database.GetOAuthConsentParams{UserID: userID, ClientID: client.ID})
+		if err == nil {
+			consented = slices.Compact(slices.Sorted(slices.Values(append(consent.Scopes, scopes...))))
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			handleError(w, r, err)
+			return
+		}
+		err = cfg.dbq.UpsertOAuthConsent(context.Background(), database.UpsertOAuthConsentParams{UserID: userID, ClientID: client.ID, Scopes: consented, CreatedAt: now})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+
+		err = cfg.dbq.CreateOAuthAuthorizationCode(context.Background(), database.CreateOAuthAuthorizationCodeParams{
+			CodeHash:      auth.HashToken(code),
+			ClientID:      client.ID,
+			UserID:        userID,
+			RedirectUri:   params.RedirectURI,
+			Scopes:        scopes,
+			CodeChallenge: params.CodeChallenge,
+			FamilyID:      uuid.New(),
+			CreatedAt:     now,
+			ExpiresAt:     now.Add(oauthCodeLifetime),
+		})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		query.Set("code", code)
+	}
+	redirect.RawQuery = query.Encode()
+
+	type returnVals struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+	respondWithJSON(w, 200, returnVals{RedirectTo: redirect.String()})
+}
+
+
+// authenticateOAuthClient identifies the client calling the token or
+// revocation endpoint, from HTTP Basic credentials or the client_id and
+// client_secret form fields. Confidential clients must present their secret,
+// public clients have none. The form must already be parsed.
+func (cfg *apiConfig) authenticateOAuthClient(w http.ResponseWriter, r *http.Request) (database.OauthClient, bool) {
+	rawClientID, secret, ok := r.BasicAuth()
+	if !ok {
+		rawClientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	clientID, err := uuid.Parse(rawClientID)
+	if err != nil {
+		respondWithOAuthError(w, 401, "invalid_client", "")
+		return database.OauthClient{}, false
+	}
+	client, err := cfg.dbq.GetOAuthClient(context.Background(), clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithOAuthError(w, 401, "invalid_client", "")
+		} else {
+			handleError(w, r, err)
+		}
+		return database.OauthClient{}, false
+	}
+	if client.SecretHash.Valid != (secret != "") ||
+		client.SecretHash.Valid && subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash.String)) != 1 {
+		respondWithOAuthError(w, 401, "invalid_client", "")
+		return database.OauthClient{}, false
+	}
+	return client, true
+}
+
+// oauthGrant is what a token request was granted.
+type oauthGrant struct {
+	UserID       uuid.UUID
+	Scopes       []string
+	RefreshToken string
+}
+
+func (cfg *apiConfig) oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, 400, "invalid_request", "malformed form body")
+		return
+	}
+	client, ok := cfg.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	var grant oauthGrant
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		grant, ok = cfg.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		grant, ok = cfg.exchangeOAuthRefreshToken(w, r, client)
+	default:
+		respondWithOAuthError(w, 400, "unsupported_grant_type", "")
+		return
+	}
+	if !ok {
+		return
+	}
+
+	accessToken, err := cfg.jwt_keys.MakeOAuthJWT(grant.UserID, client.ID, grant.Scopes, oauthAccessTokenLifetime)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	type returnVals struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, 200, returnVals{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenLifetime.Seconds()),
+		RefreshToken: grant.RefreshToken,
+		Scope:        strings.Join(grant.Scopes, " "),
+	})
+}
+
+// exchangeAuthorizationCode redeems an authorization code for the client that
+// requested it, starting a new refresh token family.
+func (cfg *apiConfig) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client database.OauthClient) (oauthGrant, bool) {
+	code, err := cfg.dbq.GetOAuthAuthorizationCode(context.Background(), auth.HashToken(r.PostForm.Get("code")))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithOAuthError(w, 400, "invalid_grant", "")
+		} else {
+			handleError(w, r, err)
+		}
+		return oauthGrant{}, false
+	}
+	if code.ClientID != client.ID {
+		respondWithOAuthError(w, 400, "invalid_grant", "")
+		return oauthGrant{}, false
+	}
+	if code.UsedAt.Valid {
+		// A code that is redeemed twice was intercepted somewhere, so the
+		// tokens from the first exchange go too (RFC 6749, section 4.1.2).
+		if err := cfg.revokeRefreshTokenFamily(code.FamilyID); !errors.Is(err, errRefreshTokenReused) {
+			handleError(w, r, err)
+			return oauthGrant{}, false
+		}
+		respondWithOAuthError(w, 400, "invalid_grant", "")
+		return oauthGrant{}, false
+	}
+	now := time.Now().UTC()
+	if code.ExpiresAt.Before(now) || code.RedirectUri != r.PostForm.Get("redirect_uri") || !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		respondWithOAuthError(w, 400, "invalid_grant", "")
+		return oauthGrant{}, false
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return oauthGrant{}, false
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	rows, err := qtx.UseOAuthAuthorizationCode(context.Background(), database.UseOAuthAuthorizationCodeParams{UsedAt: sql.NullTime{Time: now, Valid: true}, CodeHash: code.CodeHash})
+	if err != nil {
+		handleError(w, r, err)
+		return oauthGrant{}, false
+	}
+	if rows == 0 {
+		respondWithOAuthError(w, 400, "invalid_grant", "")
+		return oauthGrant{}, false
+	}
+	session := refreshSession{
+		FamilyID:  code.FamilyID,
+		StartedAt: now,
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r),
+		ClientID:  uuid.NullUUID{UUID: client.ID, Valid: true},
+		Scopes:    code.Scopes,
+	}
+	refreshToken, err := issueRefreshToken(context.Background(), qtx, code.UserID, session, "")
+	if err != nil {
+		handleError(w, r, err)
+		return oauthGrant{}, false
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return oauthGrant{}, false
+	}
+	return oauthGrant{UserID: code.UserID, Scopes: code.Scopes, RefreshToken: refreshToken}, true
+}
+
+// exchangeOAuthRefreshToken rotates a refresh token the client was granted
+// earlier, the same way /api/refresh does for first-party logins.
+func (cfg *apiConfig) exchangeOAuthRefreshToken(w http.ResponseWriter, r *http.Request, client database.OauthClient) (oauthGrant, bool) {
+	token, err := cfg.dbq.GetRefreshTokenByToken(context.Background(), r.PostForm.Get("refresh_token"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithOAuthError(w, 400, "invalid_grant", "")
+		} else {
+			handleError(w, r, err)
+		}
+		return oauthGrant{}, false
+	}
+	if !token.ClientID.Valid || token.ClientID.UUID != client.ID || token.RevokedAt.Valid || token.ExpiresAt.UTC().Before(time.Now().UTC()) {
+		respondWithOAuthError(w, 400, "invalid_grant", "")
+		return oauthGrant{}, false
+	}
+	refreshToken, err := cfg.rotateRefreshToken(r, token)
+	if err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			respondWithOAuthError(w, 400, "invalid_grant", "")
+		} else {
+			handleError(w, r, err)
+		}
+		return oauthGrant{}, false
+	}
+	return oauthGrant{UserID: token.UserID, Scopes: token.Scopes, RefreshToken: refreshToken}, true
+}
+
+// oauthRevokeHandler lets a client give up a refresh token, and with it the
+// rest of its token family (RFC 7009).
+func (cfg *apiConfig) oauthRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, 400, "invalid_request", "malformed form body")
+		return
+	}
+	client, ok := cfg.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := cfg.dbq.GetRefreshTokenByToken(context.Background(), r.PostForm.Get("token"))
+	if err == nil && token.ClientID.Valid && token.ClientID.UUID == client.ID {
+		now := time.Now().UTC()
+		err = cfg.dbq.RevokeRefreshTokenFamily(context.Background(), database.RevokeRefreshTokenFamilyParams{UpdatedAt: now, RevokedAt: sql.NullTime{Time: now, Valid: true}, FamilyID: token.FamilyID})
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		handleError(w, r, err)
+		return
+	}
+	// Unknown tokens, other clients' tokens and access tokens, which expire
+	// on their own, all get the same answer.
+	w.WriteHeader(200)
+}
+
+// billingCheckoutHandler starts a ZingPay checkout for the caller. Premium
+// begins once ZingPay reports the payment through the webhook.
+func (cfg *apiConfig) billingCheckoutHandler(w http.ResponseWriter, r *http.Request) {
+	if !cfg.requireBilling(w) {
+		return
+	}
+	type parameters struct {
+		Plan string `json:"plan"`
+	}
+	params := parameters{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			respondWithError(w, 400, "malformed request body")
+			return
+		}
+	}
+	if params.Plan == "" {
+		params.Plan = defaultPlan
+	}
+	if !slices.Contains(billingPlans, params.Plan) {
+		respondWithError(w, 400, "unknown plan")
+		return
+	}
+
+	caller := principalFrom(r)
+	user, err := cfg.dbq.GetUserByID(context.Background(), caller.UserID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// A cancelled subscription can be taken up again before it runs out.
+	subscription, err := cfg.dbq.GetSubscriptionByUser(context.Background(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		handleError(w, r, err)
+		return
+	}
+	if err == nil && caller.IsPremium && subscription.Status != "cancelled" {
+		respondWithError(w, 409, "already subscribed")
+		return
+	}
+
+	session, err := cfg.zingpay.CreateCheckoutSession(r.Context(), zingpay.CheckoutSessionParams{
+		UserID:     user.ID,
+		Email:      user.Email,
+		Plan:       params.Plan,
+		SuccessURL: cfg.base_url + "/app/?checkout=success",
+		CancelURL:  cfg.base_url + "/app/?checkout=cancelled",
+	})
+	if err != nil {
+		respondWithZingPayError(w, err)
+		return
+	}
+
+	type returnVals struct {
+		ID        string    `json:"id"`
+		URL       string    `json:"url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	respondWithJSON(w, 201, returnVals{ID: session.ID, URL: session.URL, ExpiresAt: session.ExpiresAt})
+}
+
+// webhookEventReplayHandler puts a dead-lettered webhook event back in the
+// inbox with a fresh set of attempts, once whatever made it fail is fixed.
+func (cfg *apiConfig) webhookEventReplayHandler(w http.ResponseWriter, r *http.Request) {
+	eventID := r.PathValue("eventID")
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	rows, err := qtx.ReplayWebhookEvent(context.Background(), database.ReplayWebhookEventParams{NextAttemptAt: time.Now().UTC(), ID: eventID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	event, err := qtx.GetWebhookEvent(context.Background(), eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		handleErrorNotFound(w)
+		return
+	}
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, 409, "only dead events can be replayed")
+		return
+	}
+	action := newAdminAction(r, "replay_webhook")
+	action.Details = map[string]interface{}{"event_id": event.ID, "event": event.EventType}
+	if err := recordAdminAction(context.Background(), qtx, action); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	cfg.notifyWebhookWorker()
+	respondWithJSON(w, 202, newWebhookEventResponse(event))
+}
+
+// followPostHandler makes the caller follow the user in the path. Following
+// someone twice changes nothing.
+func (cfg *apiConfig) followPostHandler(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+	if followeeID == caller.UserID {
+		respondWithError(w, 400, "you can't follow yourself")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	if _, err := qtx.GetUserByID(context.Background(), followeeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+	rows, err := qtx.FollowUser(context.Background(), database.FollowUserParams{FollowerID: caller.UserID, FolloweeID: followeeID, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if rows == 1 {
+		err = qtx.AddUserFollowCounts(context.Background(), database.AddUserFollowCountsParams{FolloweeID: followeeID, Delta: 1, FollowerID: caller.UserID})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		err = qtx.BackfillTimeline(context.Background(), database.BackfillTimelineParams{FollowerID: caller.UserID, FolloweeID: followeeID})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+	}
+	followee, err := qtx.GetUserByID(context.Background(), followeeID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	viewerFollows := true
+	respBody := newUserResponse(followee)
+	respBody.ViewerFollows = &viewerFollows
+	respondWithJSON(w, 200, respBody)
+}
+
+// likePostHandler likes the zinger in the path for the caller. Liking a
+// zinger twice changes nothing.
+func (cfg *apiConfig) likePostHandler(w http.ResponseWriter, r *http.Request) {
+	caller := principalFrom(r)
+	zingerID, err := uuid.Parse(r.PathValue("zingerID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	zinger, err := qtx.GetZingerById(context.Background(), zingerID)
+	if err != nil || zinger.DeletedAt.Valid {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+	rows, err := qtx.LikeZinger(context.Background(), database.LikeZingerParams{UserID: caller.UserID, ZingerID: zingerID, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if rows == 1 {
+		// The count is bumped in place rather than recounted, and not at
+		// all if the zinger was deleted after we looked it up.
+		rows, err = qtx.IncrementLikeCount(context.Background(), zingerID)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		if rows == 0 {
+			handleErrorNotFound(w)
+			return
+		}
+		zinger.LikeCount++
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	viewerLiked := true
+	respBody := newZingerResponse(zinger)
+	respBody.ViewerLiked = &viewerLiked
+	respondWithJSON(w, 200, respBody)
+}
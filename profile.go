@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	minHandleLength      = 3
+	maxHandleLength      = 30
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxWebsiteLength     = 100
+	maxLocationLength    = 30
+)
+
+var handlePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// reservedHandles can't be taken by users, they would be mistaken for
+// ZingZing itself, its staff or its routes.
+var reservedHandles = []string{
+	"about", "abuse", "admin", "administrator", "api", "app", "billing", "help",
+	"login", "logout", "me", "moderator", "null", "oauth", "official", "root",
+	"security", "settings", "signup", "staff", "support", "system", "timeline",
+	"undefined", "www", "zingpay", "zingzing",
+}
+
+var errHandleTaken = errors.New("handle is already taken")
+
+// validateHandle checks a handle a user picked: a letter, then letters,
+// digits and underscores, and not a reserved name in any case.
+func validateHandle(handle string) error {
+	if len(handle) < minHandleLength || len(handle) > maxHandleLength {
+		return fmt.Errorf("handle must be between %d and %d characters", minHandleLength, maxHandleLength)
+	}
+	if !handlePattern.MatchString(handle) {
+		return errors.New("handle must start with a letter and contain only letters, digits and underscores")
+	}
+	if slices.Contains(reservedHandles, strings.ToLower(handle)) {
+		return errors.New("handle is reserved")
+	}
+	return nil
+}
+
+// defaultHandle is the handle of users who didn't pick one.
+func defaultHandle(userID uuid.UUID) string {
+	return "user_" + strings.ReplaceAll(userID.String(), "-", "")[:12]
+}
+
+// isHandleTaken reports whether err is the unique index on handles
+// rejecting a write.
+func isHandleTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_handle_lower_idx"
+}
+
+// profileFields are the free-form parts of a profile. Nil fields are left
+// as they are.
+type profileFields struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	Website     *string `json:"website"`
+	Location    *string `json:"location"`
+}
+
+// validate trims the fields and checks their lengths. Websites must be
+// http(s) URLs; an empty string clears any field.
+func (f *profileFields) validate() error {
+	limits := []struct {
+		name  string
+		value *string
+		max   int
+	}{
+		{"display_name", f.DisplayName, maxDisplayNameLength},
+		{"bio", f.Bio, maxBioLength},
+		{"website", f.Website, maxWebsiteLength},
+		{"location", f.Location, maxLocationLength},
+	}
+	for _, limit := range limits {
+		if limit.value == nil {
+			continue
+		}
+		*limit.value = strings.TrimSpace(*limit.value)
+		if utf8.RuneCountInString(*limit.value) > limit.max {
+			return fmt.Errorf("%s must be at most %d characters", limit.name, limit.max)
+		}
+	}
+	if f.Website != nil && *f.Website != "" {
+		u, err := url.Parse(*f.Website)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("website must be an http or https URL")
+		}
+	}
+	return nil
+}
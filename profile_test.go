@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateHandle(t *testing.T) {
+	for _, handle := range []string{"zed", "Alice_99", "a" + strings.Repeat("b", maxHandleLength-1), defaultHandle(uuid.New())} {
+		assert.NoError(t, validateHandle(handle), handle)
+	}
+	for _, handle := range []string{"", "ab", strings.Repeat("a", maxHandleLength+1), "9lives", "_under", "has space", "dash-ed", "émile", "Admin", "ZINGZING", "me"} {
+		assert.Error(t, validateHandle(handle), handle)
+	}
+}
+
+func TestProfileFieldsValidate(t *testing.T) {
+	s := func(v string) *string { return &v }
+
+	fields := profileFields{DisplayName: s("  Zed  "), Website: s("https://zed.example/about")}
+	require.NoError(t, fields.validate())
+	assert.Equal(t, "Zed", *fields.DisplayName)
+	assert.Nil(t, fields.Bio)
+
+	assert.NoError(t, (&profileFields{Website: s("")}).validate(), "clearing")
+	assert.NoError(t, (&profileFields{Bio: s(strings.Repeat("ü", maxBioLength))}).validate(), "characters, not bytes")
+	assert.Error(t, (&profileFields{Bio: s(strings.Repeat("a", maxBioLength+1))}).validate())
+	assert.Error(t, (&profileFields{Website: s("javascript:alert(1)")}).validate())
+	assert.Error(t, (&profileFields{Website: s("zed.example")}).validate())
+}
+
+func TestProfiles(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	alice, aliceToken := createTestUser(t, cfg)
+	_, bobToken := createTestUser(t, cfg)
+	handle := "alice_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+
+	type profile struct {
+		userResponse
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	var got profile
+	resp := doJSON(t, "PATCH", api.URL+"/api/users/me", aliceToken, map[string]string{"handle": handle, "bio": "Hi!"}, &got)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, handle, got.Handle)
+	assert.Equal(t, "Hi!", got.Bio)
+	assert.Equal(t, alice.Email, got.Email, "untouched")
+	assert.True(t, got.EmailVerified)
+
+	resp = doJSON(t, "PATCH", api.URL+"/api/users/me", aliceToken, map[string]string{"display_name": "Alice"}, &got)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "Hi!", got.Bio, "partial updates keep the rest")
+
+	resp = doJSON(t, "PATCH", api.URL+"/api/users/me", bobToken, map[string]string{"handle": strings.ToUpper(handle)}, nil)
+	assert.Equal(t, 409, resp.StatusCode, "handles are unique in any case")
+	resp = doJSON(t, "PATCH", api.URL+"/api/users/me", bobToken, map[string]string{"handle": "support"}, nil)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	var public userResponse
+	resp = doJSON(t, "GET", api.URL+"/api/users/"+strings.ToUpper(handle), "", nil, &public)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, userResponse{ID: alice.ID, Handle: handle, DisplayName: "Alice", Bio: "Hi!", CreatedAt: public.CreatedAt}, public)
+
+	resp = doJSON(t, "GET", api.URL+"/api/users/"+handle, bobToken, nil, &public)
+	require.Equal(t, 200, resp.StatusCode)
+	require.NotNil(t, public.ViewerFollows)
+	assert.False(t, *public.ViewerFollows)
+
+	resp = doJSON(t, "GET", api.URL+"/api/users/nobody_"+strings.ReplaceAll(uuid.NewString(), "-", "")[:8], "", nil, nil)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	t.Run("Credentials", func(t *testing.T) {
+		for _, field := range []string{"email", "password"} {
+			resp := doJSON(t, "PATCH", api.URL+"/api/users/me", aliceToken, map[string]string{field: "changed"}, nil)
+			assert.Equal(t, 400, resp.StatusCode, field)
+		}
+	})
+
+	t.Run("Unverified", func(t *testing.T) {
+		cfg.unverified_read_only = true
+		t.Cleanup(func() { cfg.unverified_read_only = false })
+		carol, carolToken := createTestUser(t, cfg)
+		unverifyTestUser(t, cfg, carol.ID)
+		resp := doJSON(t, "PATCH", api.URL+"/api/users/me", carolToken, map[string]string{"bio": "spam"}, nil)
+		assert.Equal(t, 403, resp.StatusCode)
+	})
+}
@@ -6,16 +6,23 @@ import (
 	"encoding/json"
 	"github.com/bsuvonov/zingzing/internal/auth"
 	"context"
-	"errors"
 	"github.com/bsuvonov/zingzing/internal/database"
 	"time"
+	"log"
+	"errors"
+	"database/sql"
+	"github.com/google/uuid"
 )
 
 
+// putUsersHandler changes the caller's email and password. It is for
+// sessions only and asks for the current password, so that a leaked token or
+// an unattended session can't take the account over.
 func (cfg *apiConfig) putUsersHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email string `json:"email"`
 		Password string `json:"password"`
+		CurrentPassword string `json:"current_password"`
 	}
 	params := parameters{}
 	decoder := json.NewDecoder(r.Body)
@@ -25,34 +32,39 @@ func (cfg *apiConfig) putUsersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	user_id := principalFrom(r).UserID
+
+	user, err := cfg.dbq.GetUserByID(context.Background(), user_id)
 	if err != nil {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Unauthorized"))
+		handleError(w, r, err)
 		return
 	}
-	user_id, err := auth.ValidateJWT(token, cfg.jwt_secret)
-	if err != nil {
-		if errors.Is(err, auth.ErrInvalidJWT) {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.WriteHeader(401)
-			w.Write([]byte("Unauthorized"))
-			return
-		} else {
-			handleError(w, r, err)
-			return
-		}
+	if _, err := cfg.hasher.Check(user.HashedPassword, params.CurrentPassword); err != nil {
+		respondWithError(w, 403, "current password is incorrect")
+		return
+	}
+	if params.Email == "" || params.Password == "" {
+		respondWithError(w, 400, "email and password are required")
+		return
 	}
-	
-	hashed_pwd, err := auth.HashPassword(params.Password)
+
+	hashed_pwd, err := cfg.hasher.Hash(params.Password)
 	if err != nil {
 		handleError(w, r, err)
 		return
 	}
-	err = cfg.dbq.UpdateUser(context.Background(), database.UpdateUserParams{Email: params.Email, UpdatedAt: time.Now(), HashedPassword: hashed_pwd, ID: user_id})
+	err = cfg.dbq.UpdateUser(context.Background(), database.UpdateUserParams{Email: params.Email, UpdatedAt: time.Now().UTC(), HashedPassword: hashed_pwd, ID: user_id})
 	if err != nil {
 		handleError(w, r, err)
+		return
+	}
+	if params.Email != user.Email {
+		// UpdateUser cleared the verification, the new address needs its own.
+		user.Email = params.Email
+		err = cfg.sendEmailVerification(context.Background(), user)
+		if err != nil {
+			log.Printf("Error sending verification email to user %s: %s", user.ID, err)
+		}
 	}
 
 	type returnVals struct {
@@ -67,3 +79,66 @@ func (cfg *apiConfig) putUsersHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(dat)
 }
 
+
+
+func (cfg *apiConfig) userRolePutHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if !auth.ValidRole(params.Role) {
+		respondWithError(w, 400, "role must be one of user, moderator or admin")
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		handleErrorNotFound(w)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	user, err := qtx.GetUserByID(context.Background(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			handleErrorNotFound(w)
+			return
+		}
+		handleError(w, r, err)
+		return
+	}
+	err = qtx.SetUserRole(context.Background(), database.SetUserRoleParams{Role: params.Role, UpdatedAt: time.Now().UTC(), ID: user.ID})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	action := newAdminAction(r, "set_role")
+	action.TargetID = uuid.NullUUID{UUID: user.ID, Valid: true}
+	action.Details = map[string]interface{}{"from": user.Role, "to": params.Role}
+	if err := recordAdminAction(context.Background(), qtx, action); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	type returnVals struct {
+		ID    uuid.UUID `json:"id"`
+		Email string    `json:"email"`
+		Role  string    `json:"role"`
+	}
+	respondWithJSON(w, 200, returnVals{ID: user.ID, Email: user.Email, Role: params.Role})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type refreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// startTestSession issues the first refresh token of a new login for user.
+func startTestSession(t *testing.T, cfg *apiConfig, userID uuid.UUID) string {
+	t.Helper()
+	token, err := issueRefreshToken(context.Background(), cfg.dbq, userID, newRefreshSession(httptest.NewRequest("POST", "/api/login", nil)), "")
+	require.NoError(t, err)
+	return token
+}
+
+func TestRefreshRotation(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	user, _ := createTestUser(t, cfg)
+
+	t.Run("Rotate", func(t *testing.T) {
+		first := startTestSession(t, cfg, user.ID)
+		var got refreshResponse
+		resp := doJSON(t, "POST", api.URL+"/api/refresh", first, nil, &got)
+		require.Equal(t, 200, resp.StatusCode)
+		require.NotEmpty(t, got.Token)
+		require.NotEqual(t, first, got.RefreshToken)
+
+		old, err := cfg.dbq.GetRefreshTokenByToken(context.Background(), first)
+		require.NoError(t, err)
+		assert.True(t, old.UsedAt.Valid)
+		next, err := cfg.dbq.GetRefreshTokenByToken(context.Background(), got.RefreshToken)
+		require.NoError(t, err)
+		assert.Equal(t, old.FamilyID, next.FamilyID)
+		assert.Equal(t, first, next.ParentToken.String)
+		assert.False(t, next.UsedAt.Valid || next.RevokedAt.Valid)
+
+		// The successor rotates in turn.
+		var again refreshResponse
+		resp = doJSON(t, "POST", api.URL+"/api/refresh", got.RefreshToken, nil, &again)
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("Replay revokes the family", func(t *testing.T) {
+		first := startTestSession(t, cfg, user.ID)
+		var got refreshResponse
+		resp := doJSON(t, "POST", api.URL+"/api/refresh", first, nil, &got)
+		require.Equal(t, 200, resp.StatusCode)
+
+		resp = doJSON(t, "POST", api.URL+"/api/refresh", first, nil, nil)
+		assert.Equal(t, 401, resp.StatusCode)
+
+		current, err := cfg.dbq.GetRefreshTokenByToken(context.Background(), got.RefreshToken)
+		require.NoError(t, err)
+		assert.True(t, current.RevokedAt.Valid)
+		resp = doJSON(t, "POST", api.URL+"/api/refresh", got.RefreshToken, nil, nil)
+		assert.Equal(t, 401, resp.StatusCode)
+
+		// Other logins of the same user are left alone.
+		other := startTestSession(t, cfg, user.ID)
+		resp = doJSON(t, "POST", api.URL+"/api/refresh", other, nil, nil)
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token := startTestSession(t, cfg, user.ID)
+		_, err := cfg.db.Exec("UPDATE refresh_tokens SET expires_at = $1 WHERE token = $2", time.Now().UTC().Add(-time.Minute), token)
+		require.NoError(t, err)
+		resp := doJSON(t, "POST", api.URL+"/api/refresh", token, nil, nil)
+		assert.Equal(t, 401, resp.StatusCode)
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		token := startTestSession(t, cfg, user.ID)
+		resp := doJSON(t, "POST", api.URL+"/api/revoke", token, nil, nil)
+		require.Equal(t, 204, resp.StatusCode)
+		resp = doJSON(t, "POST", api.URL+"/api/refresh", token, nil, nil)
+		assert.Equal(t, 401, resp.StatusCode)
+
+		resp = doJSON(t, "POST", api.URL+"/api/revoke", "", nil, nil)
+		assert.Equal(t, 401, resp.StatusCode, "no token to revoke")
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		resp := doJSON(t, "POST", api.URL+"/api/refresh", "not-a-refresh-token", nil, nil)
+		assert.Equal(t, 401, resp.StatusCode)
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zingerContext is the response of GET /api/zingers/{zingerID}/context.
+type zingerContext struct {
+	Zinger      zingerResponse   `json:"zinger"`
+	Ancestors   []zingerResponse `json:"ancestors"`
+	Descendants []struct {
+		zingerResponse
+		Depth int32 `json:"depth"`
+	} `json:"descendants"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+func TestReplies(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	_, aliceToken := createTestUser(t, cfg)
+	_, bobToken := createTestUser(t, cfg)
+
+	post := func(token string, inReplyTo *uuid.UUID) zingerResponse {
+		t.Helper()
+		var zinger zingerResponse
+		resp := doJSON(t, "POST", api.URL+"/api/zingers", token, map[string]interface{}{"body": "zing", "in_reply_to_id": inReplyTo}, &zinger)
+		require.Equal(t, 201, resp.StatusCode)
+		return zinger
+	}
+	get := func(id uuid.UUID) zingerResponse {
+		t.Helper()
+		var zinger zingerResponse
+		resp := doJSON(t, "GET", api.URL+"/api/zingers/"+id.String(), "", nil, &zinger)
+		require.Equal(t, 200, resp.StatusCode)
+		return zinger
+	}
+	thread := func(id uuid.UUID, query string) zingerContext {
+		t.Helper()
+		var got zingerContext
+		resp := doJSON(t, "GET", api.URL+"/api/zingers/"+id.String()+"/context"+query, "", nil, &got)
+		require.Equal(t, 200, resp.StatusCode)
+		return got
+	}
+
+	// root
+	// ├── a
+	// │   └── a1
+	// └── b
+	root := post(aliceToken, nil)
+	a := post(bobToken, &root.Id)
+	a1 := post(aliceToken, &a.Id)
+	b := post(aliceToken, &root.Id)
+
+	assert.Equal(t, root.Id, root.ConversationID)
+	assert.Equal(t, root.Id, a1.ConversationID)
+	assert.Equal(t, &a.Id, a1.InReplyToID)
+	assert.Equal(t, int32(2), get(root.Id).ReplyCount)
+
+	got := thread(a1.Id, "")
+	assert.Equal(t, []uuid.UUID{root.Id, a.Id}, zingerIDs(got.Ancestors))
+	assert.Empty(t, got.Descendants)
+
+	var tree []uuid.UUID
+	var depths []int32
+	query := "?limit=1"
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 10)
+		got := thread(root.Id, query)
+		assert.Empty(t, got.Ancestors)
+		for _, d := range got.Descendants {
+			tree = append(tree, d.Id)
+			depths = append(depths, d.Depth)
+		}
+		if got.NextCursor == nil {
+			break
+		}
+		query = "?limit=1&cursor=" + *got.NextCursor
+	}
+	assert.Equal(t, []uuid.UUID{a.Id, a1.Id, b.Id}, tree, "depth first")
+	assert.Equal(t, []int32{1, 2, 1}, depths)
+
+	t.Run("Tombstone", func(t *testing.T) {
+		resp := doJSON(t, "DELETE", api.URL+"/api/zingers/"+a.Id.String(), bobToken, nil, nil)
+		require.Equal(t, 204, resp.StatusCode)
+		resp = doJSON(t, "GET", api.URL+"/api/zingers/"+a.Id.String(), "", nil, nil)
+		assert.Equal(t, 404, resp.StatusCode)
+		resp = doJSON(t, "DELETE", api.URL+"/api/zingers/"+a.Id.String(), bobToken, nil, nil)
+		assert.Equal(t, 404, resp.StatusCode, "already deleted")
+		resp = doJSON(t, "DELETE", api.URL+"/api/zingers/"+uuid.NewString(), bobToken, nil, nil)
+		assert.Equal(t, 404, resp.StatusCode)
+		resp = doJSON(t, "DELETE", api.URL+"/api/zingers/not-a-zinger", bobToken, nil, nil)
+		assert.Equal(t, 404, resp.StatusCode)
+
+		got := thread(a1.Id, "")
+		require.Len(t, got.Ancestors, 2, "the thread stays intact")
+		assert.True(t, got.Ancestors[1].Deleted)
+		assert.Empty(t, got.Ancestors[1].Body)
+
+		resp = doJSON(t, "POST", api.URL+"/api/zingers", aliceToken, map[string]interface{}{"body": "too late", "in_reply_to_id": a.Id}, nil)
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+
+	t.Run("Delete a leaf", func(t *testing.T) {
+		resp := doJSON(t, "DELETE", api.URL+"/api/zingers/"+b.Id.String(), aliceToken, nil, nil)
+		require.Equal(t, 204, resp.StatusCode)
+		assert.Equal(t, int32(1), get(root.Id).ReplyCount)
+		assert.Equal(t, []uuid.UUID{a.Id, a1.Id}, func() []uuid.UUID {
+			ids := []uuid.UUID{}
+			for _, d := range thread(root.Id, "").Descendants {
+				ids = append(ids, d.Id)
+			}
+			return ids
+		}())
+	})
+
+	t.Run("Delete the last reply of a tombstone", func(t *testing.T) {
+		resp := doJSON(t, "DELETE", api.URL+"/api/zingers/"+a1.Id.String(), aliceToken, nil, nil)
+		require.Equal(t, 204, resp.StatusCode)
+		assert.Equal(t, int32(0), get(root.Id).ReplyCount, "the tombstone goes too")
+		assert.Empty(t, thread(root.Id, "").Descendants)
+		var n int
+		require.NoError(t, cfg.db.QueryRow("SELECT count(*) FROM zingers WHERE id = $1", a.Id).Scan(&n))
+		assert.Zero(t, n)
+	})
+}
+
+func zingerIDs(zingers []zingerResponse) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, z := range zingers {
+		ids = append(ids, z.Id)
+	}
+	return ids
+}
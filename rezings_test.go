@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRezings(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	alice, aliceToken := createTestUser(t, cfg)
+	bob, bobToken := createTestUser(t, cfg)
+	_, carolToken := createTestUser(t, cfg)
+	resp := doJSON(t, "POST", api.URL+"/api/users/"+bob.ID.String()+"/follow", carolToken, nil, nil)
+	require.Equal(t, 200, resp.StatusCode)
+
+	var original zingerResponse
+	resp = doJSON(t, "POST", api.URL+"/api/zingers", aliceToken, map[string]string{"body": "worth sharing"}, &original)
+	require.Equal(t, 201, resp.StatusCode)
+	get := func(id uuid.UUID) zingerResponse {
+		t.Helper()
+		var zinger zingerResponse
+		resp := doJSON(t, "GET", api.URL+"/api/zingers/"+id.String(), "", nil, &zinger)
+		require.Equal(t, 200, resp.StatusCode)
+		return zinger
+	}
+	rezingURL := api.URL + "/api/zingers/" + original.Id.String() + "/rezing"
+
+	var rezing zingerResponse
+	resp = doJSON(t, "POST", rezingURL, bobToken, nil, &rezing)
+	require.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, bob.ID, rezing.UserId)
+	assert.Equal(t, &original.Id, rezing.RezingOfID)
+	require.NotNil(t, rezing.RezingOf)
+	assert.Equal(t, alice.ID, rezing.RezingOf.UserId)
+	assert.Equal(t, int32(1), rezing.RezingOf.RezingCount)
+
+	resp = doJSON(t, "POST", rezingURL, bobToken, nil, nil)
+	assert.Equal(t, 409, resp.StatusCode, "rezinging twice")
+	resp = doJSON(t, "POST", api.URL+"/api/zingers/"+rezing.Id.String()+"/rezing", bobToken, nil, nil)
+	assert.Equal(t, 409, resp.StatusCode, "rezinging the rezing rezings the original")
+	assert.Equal(t, int32(1), get(original.Id).RezingCount)
+
+	t.Run("Timeline", func(t *testing.T) {
+		var page zingerList
+		resp := doJSON(t, "GET", api.URL+"/api/timeline/home", carolToken, nil, &page)
+		require.Equal(t, 200, resp.StatusCode)
+		require.Len(t, page.Zingers, 1)
+		assert.Equal(t, bob.ID, page.Zingers[0].UserId)
+		require.NotNil(t, page.Zingers[0].RezingOf)
+		assert.Equal(t, "worth sharing", page.Zingers[0].RezingOf.Body)
+	})
+
+	t.Run("Quote", func(t *testing.T) {
+		var quote zingerResponse
+		resp := doJSON(t, "POST", api.URL+"/api/zingers", bobToken, map[string]interface{}{"body": "so true", "quote_of_id": original.Id}, &quote)
+		require.Equal(t, 201, resp.StatusCode)
+		assert.Equal(t, "so true", quote.Body)
+		require.NotNil(t, quote.QuoteOf)
+		assert.Equal(t, original.Id, quote.QuoteOf.Id)
+		assert.Equal(t, int32(1), get(original.Id).QuoteCount)
+
+		resp = doJSON(t, "POST", api.URL+"/api/zingers", bobToken, map[string]interface{}{"body": "?", "quote_of_id": uuid.New()}, nil)
+		assert.Equal(t, 400, resp.StatusCode)
+
+		// The quoted zinger is deleted: the quote stays, with a tombstone.
+		resp = doJSON(t, "DELETE", api.URL+"/api/zingers/"+original.Id.String(), aliceToken, nil, nil)
+		require.Equal(t, 204, resp.StatusCode)
+		quote = get(quote.Id)
+		require.NotNil(t, quote.QuoteOf)
+		assert.True(t, quote.QuoteOf.Deleted)
+		assert.Empty(t, quote.QuoteOf.Body)
+		assert.Equal(t, int32(0), quote.QuoteOf.RezingCount, "the deleted rezings don't count")
+
+		resp = doJSON(t, "GET", api.URL+"/api/zingers/"+rezing.Id.String(), "", nil, nil)
+		assert.Equal(t, 404, resp.StatusCode, "rezings go with the original")
+
+		// Once the last quote is gone the tombstone isn't needed.
+		resp = doJSON(t, "DELETE", api.URL+"/api/zingers/"+quote.Id.String(), bobToken, nil, nil)
+		require.Equal(t, 204, resp.StatusCode)
+		var n int
+		require.NoError(t, cfg.db.QueryRow("SELECT count(*) FROM zingers WHERE id = $1", original.Id).Scan(&n))
+		assert.Zero(t, n)
+	})
+}
+
+func TestRezingUndo(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	_, aliceToken := createTestUser(t, cfg)
+	_, bobToken := createTestUser(t, cfg)
+
+	var original zingerResponse
+	resp := doJSON(t, "POST", api.URL+"/api/zingers", aliceToken, map[string]string{"body": "undo me"}, &original)
+	require.Equal(t, 201, resp.StatusCode)
+	rezingURL := api.URL + "/api/zingers/" + original.Id.String() + "/rezing"
+
+	resp = doJSON(t, "POST", rezingURL, bobToken, nil, nil)
+	require.Equal(t, 201, resp.StatusCode)
+	for i := 0; i < 2; i++ {
+		resp = doJSON(t, "DELETE", rezingURL, bobToken, nil, nil)
+		require.Equal(t, 204, resp.StatusCode)
+	}
+	var got zingerResponse
+	resp = doJSON(t, "GET", api.URL+"/api/zingers/"+original.Id.String(), "", nil, &got)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, int32(0), got.RezingCount)
+
+	resp = doJSON(t, "POST", rezingURL, bobToken, nil, nil)
+	assert.Equal(t, 201, resp.StatusCode, "rezinging again after taking it back")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	alice, aliceToken := createTestUser(t, cfg)
+	bob, _ := createTestUser(t, cfg)
+	family := func(refreshToken string) uuid.UUID {
+		t.Helper()
+		token, err := cfg.dbq.GetRefreshTokenByToken(context.Background(), refreshToken)
+		require.NoError(t, err)
+		return token.FamilyID
+	}
+	list := func() []uuid.UUID {
+		t.Helper()
+		var sessions []struct {
+			ID uuid.UUID `json:"id"`
+		}
+		resp := doJSON(t, "GET", api.URL+"/api/sessions", aliceToken, nil, &sessions)
+		require.Equal(t, 200, resp.StatusCode)
+		ids := []uuid.UUID{}
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	refresh := func(refreshToken string) int {
+		t.Helper()
+		return doJSON(t, "POST", api.URL+"/api/refresh", refreshToken, nil, nil).StatusCode
+	}
+
+	laptop := startTestSession(t, cfg, alice.ID)
+	phone := startTestSession(t, cfg, alice.ID)
+	bobs := startTestSession(t, cfg, bob.ID)
+
+	// A refresh moves a session on to a new token, it doesn't start another.
+	var rotated refreshResponse
+	resp := doJSON(t, "POST", api.URL+"/api/refresh", laptop, nil, &rotated)
+	require.Equal(t, 200, resp.StatusCode)
+	laptop = rotated.RefreshToken
+	assert.ElementsMatch(t, []uuid.UUID{family(laptop), family(phone)}, list())
+
+	t.Run("Another user's session", func(t *testing.T) {
+		resp := doJSON(t, "DELETE", api.URL+"/api/sessions/"+family(bobs).String(), aliceToken, nil, nil)
+		assert.Equal(t, 404, resp.StatusCode)
+		resp = doJSON(t, "DELETE", api.URL+"/api/sessions/not-a-session", aliceToken, nil, nil)
+		assert.Equal(t, 404, resp.StatusCode)
+		assert.Equal(t, 200, refresh(bobs), "left alone")
+	})
+	bobs = startTestSession(t, cfg, bob.ID)
+
+	resp = doJSON(t, "DELETE", api.URL+"/api/sessions/"+family(laptop).String(), aliceToken, nil, nil)
+	require.Equal(t, 204, resp.StatusCode)
+	assert.Equal(t, 401, refresh(laptop))
+	assert.Equal(t, []uuid.UUID{family(phone)}, list())
+
+	resp = doJSON(t, "DELETE", api.URL+"/api/sessions", aliceToken, nil, nil)
+	require.Equal(t, 204, resp.StatusCode)
+	assert.Equal(t, 401, refresh(phone))
+	assert.Empty(t, list())
+	assert.Equal(t, 200, refresh(bobs), "other users stay logged in")
+}
@@ -0,0 +1,11 @@
+-- name: CreateAdminAuditLogEntry :exec
+INSERT INTO admin_audit_log (id, actor_id, action, target_user_id, details, ip_address, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+);
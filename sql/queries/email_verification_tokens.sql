@@ -0,0 +1,18 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: UseEmailVerificationToken :one
+UPDATE email_verification_tokens SET used_at = $1
+WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $3
+RETURNING *;
+
+-- name: InvalidateEmailVerificationTokens :exec
+UPDATE email_verification_tokens SET used_at = $1
+WHERE user_id = $2 AND used_at IS NULL;
@@ -0,0 +1,44 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: IsFollowing :one
+SELECT EXISTS (
+    SELECT 1 FROM follows WHERE follower_id = $1 AND followee_id = $2
+) AS is_following;
+
+-- name: ListFollowers :many
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio, users.website, users.location,
+    users.follower_count, users.following_count, follows.created_at AS followed_at,
+    EXISTS (
+        SELECT 1 FROM follows viewer
+        WHERE viewer.follower_id = sqlc.narg(viewer_id) AND viewer.followee_id = users.id
+    ) AS viewer_follows
+FROM follows
+JOIN users ON users.id = follows.follower_id
+WHERE follows.followee_id = sqlc.arg(user_id)
+    AND (sqlc.narg(after_created_at)::timestamp IS NULL OR (follows.created_at, follows.follower_id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+ORDER BY follows.created_at DESC, follows.follower_id DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: ListFollowing :many
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio, users.website, users.location,
+    users.follower_count, users.following_count, follows.created_at AS followed_at,
+    EXISTS (
+        SELECT 1 FROM follows viewer
+        WHERE viewer.follower_id = sqlc.narg(viewer_id) AND viewer.followee_id = users.id
+    ) AS viewer_follows
+FROM follows
+JOIN users ON users.id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg(user_id)
+    AND (sqlc.narg(after_created_at)::timestamp IS NULL OR (follows.created_at, follows.followee_id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+ORDER BY follows.created_at DESC, follows.followee_id DESC
+LIMIT sqlc.arg(row_limit);
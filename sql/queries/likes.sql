@@ -0,0 +1,34 @@
+-- name: LikeZinger :execrows
+INSERT INTO likes (user_id, zinger_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, zinger_id) DO NOTHING;
+
+-- name: UnlikeZinger :execrows
+DELETE FROM likes WHERE user_id = $1 AND zinger_id = $2;
+
+-- name: GetLikedZingerIDs :many
+SELECT zinger_id FROM likes
+WHERE user_id = $1 AND zinger_id = ANY(sqlc.arg(zinger_ids)::uuid[]);
+
+-- name: ListZingerLikes :many
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio, users.website, users.location,
+    users.follower_count, users.following_count, likes.created_at AS liked_at
+FROM likes
+JOIN users ON users.id = likes.user_id
+WHERE likes.zinger_id = sqlc.arg(zinger_id)
+    AND (sqlc.narg(after_created_at)::timestamp IS NULL OR (likes.created_at, likes.user_id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+ORDER BY likes.created_at DESC, likes.user_id DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: ListUserLikes :many
+SELECT sqlc.embed(zingers), likes.created_at AS liked_at
+FROM likes
+JOIN zingers ON zingers.id = likes.zinger_id
+WHERE likes.user_id = sqlc.arg(user_id) AND zingers.deleted_at IS NULL
+    AND (sqlc.narg(after_created_at)::timestamp IS NULL OR (likes.created_at, likes.zinger_id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+ORDER BY likes.created_at DESC, likes.zinger_id DESC
+LIMIT sqlc.arg(row_limit);
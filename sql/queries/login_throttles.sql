@@ -0,0 +1,16 @@
+-- name: GetLoginThrottles :many
+SELECT * FROM login_throttles WHERE key = ANY(sqlc.arg(keys)::text[]);
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (key, failures, last_failure_at)
+VALUES (sqlc.arg(key), 1, sqlc.arg(now))
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE WHEN login_throttles.last_failure_at < sqlc.arg(window_start) THEN 1 ELSE login_throttles.failures + 1 END,
+    last_failure_at = sqlc.arg(now)
+RETURNING *;
+
+-- name: SetLoginLockout :exec
+UPDATE login_throttles SET locked_until = $1 WHERE key = $2;
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles WHERE key = $1;
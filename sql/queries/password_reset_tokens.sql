@@ -0,0 +1,17 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+);
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_tokens SET used_at = $1
+WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $3
+RETURNING *;
+
+-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens SET used_at = $1
+WHERE user_id = $2 AND used_at IS NULL;
@@ -1,11 +1,19 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, family_id, parent_token, session_started_at, last_used_at, user_agent, ip_address, client_id, scopes)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10,
+    $11,
+    $12,
+    $13
 )
 RETURNING *;
 
@@ -14,3 +22,24 @@ SELECT * FROM refresh_tokens WHERE token = $1;
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens SET updated_at = $1, revoked_at = $2 WHERE token = $3;
+
+-- name: MarkRefreshTokenUsed :execrows
+UPDATE refresh_tokens SET updated_at = $1, used_at = $2
+WHERE token = $3 AND used_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens SET updated_at = $1, revoked_at = $2
+WHERE family_id = $3 AND revoked_at IS NULL;
+
+-- name: GetActiveSessionsByUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND used_at IS NULL AND expires_at > $2
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSessionForUser :execrows
+UPDATE refresh_tokens SET updated_at = $1, revoked_at = $2
+WHERE family_id = $3 AND user_id = $4 AND revoked_at IS NULL;
+
+-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens SET updated_at = $1, revoked_at = $2
+WHERE user_id = $3 AND revoked_at IS NULL;
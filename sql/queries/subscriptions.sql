@@ -0,0 +1,46 @@
+-- A subscription grants premium until its current period ends, or until the
+-- end of the grace period after a failed payment.
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, user_id, plan, status, current_period_end, created_at, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    'active',
+    $4,
+    $5,
+    $5
+)
+ON CONFLICT (user_id) DO UPDATE SET plan = EXCLUDED.plan, status = 'active', current_period_end = EXCLUDED.current_period_end,
+    grace_period_end = NULL, updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetSubscriptionByUser :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: RenewSubscription :execrows
+UPDATE subscriptions SET status = 'active', current_period_end = $1, grace_period_end = NULL, updated_at = $2
+WHERE user_id = $3;
+
+-- name: CancelSubscription :execrows
+UPDATE subscriptions SET status = 'cancelled', grace_period_end = NULL, updated_at = $1
+WHERE user_id = $2 AND status <> 'expired';
+
+-- name: MarkSubscriptionPastDue :execrows
+UPDATE subscriptions SET status = 'past_due', grace_period_end = $1, updated_at = $2
+WHERE user_id = $3 AND status IN ('active', 'past_due');
+
+-- name: ExpireSubscription :execrows
+UPDATE subscriptions SET status = 'expired', current_period_end = LEAST(current_period_end, sqlc.arg(now)), grace_period_end = NULL, updated_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id) AND status <> 'expired';
+
+-- name: ExpireLapsedSubscriptions :execrows
+UPDATE subscriptions SET status = 'expired', updated_at = sqlc.arg(now)
+WHERE status <> 'expired' AND COALESCE(grace_period_end, current_period_end) <= sqlc.arg(now);
+
+-- name: IsUserPremium :one
+SELECT EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE user_id = sqlc.arg(user_id) AND status <> 'expired' AND COALESCE(grace_period_end, current_period_end) > sqlc.arg(now)
+) AS is_premium;
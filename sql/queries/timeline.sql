@@ -0,0 +1,44 @@
+-- name: FanOutZinger :exec
+WITH zinger AS (
+    UPDATE zingers SET fanned_out = users.follower_count < sqlc.arg(fanout_threshold)::integer
+    FROM users
+    WHERE zingers.id = sqlc.arg(zinger_id)::uuid AND users.id = zingers.user_id
+    RETURNING zingers.id, zingers.user_id, zingers.created_at, zingers.fanned_out
+)
+INSERT INTO timeline_entries (user_id, zinger_id, author_id, created_at)
+SELECT zinger.user_id, zinger.id, zinger.user_id, zinger.created_at
+FROM zinger
+UNION ALL
+SELECT follows.follower_id, zinger.id, zinger.user_id, zinger.created_at
+FROM zinger
+JOIN follows ON follows.followee_id = zinger.user_id
+WHERE zinger.fanned_out
+ON CONFLICT DO NOTHING;
+
+-- name: BackfillTimeline :exec
+INSERT INTO timeline_entries (user_id, zinger_id, author_id, created_at)
+SELECT sqlc.arg(follower_id)::uuid, zingers.id, zingers.user_id, zingers.created_at
+FROM zingers
+WHERE zingers.user_id = sqlc.arg(followee_id) AND zingers.fanned_out
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveAuthorFromTimeline :exec
+DELETE FROM timeline_entries WHERE user_id = $1 AND author_id = $2;
+
+-- name: GetHomeTimeline :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out FROM (
+    SELECT zingers.*
+    FROM timeline_entries
+    JOIN zingers ON zingers.id = timeline_entries.zinger_id
+    WHERE timeline_entries.user_id = sqlc.arg(viewer_id) AND zingers.deleted_at IS NULL
+        AND (sqlc.narg(after_created_at)::timestamp IS NULL OR (timeline_entries.created_at, timeline_entries.zinger_id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+    UNION
+    SELECT zingers.*
+    FROM follows
+    JOIN zingers ON zingers.user_id = follows.followee_id
+    WHERE follows.follower_id = sqlc.arg(viewer_id) AND NOT zingers.fanned_out
+        AND zingers.deleted_at IS NULL
+        AND (sqlc.narg(after_created_at)::timestamp IS NULL OR (zingers.created_at, zingers.id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+) timeline
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(row_limit);
@@ -1,17 +1,24 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6
 )
 RETURNING *;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE email = $1;
 
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users WHERE LOWER(handle) = LOWER($1);
+
 -- name: GetUserByRefreshToken :one
 WITH token_user AS (
     SELECT user_id FROM refresh_tokens WHERE token = $1
@@ -19,7 +26,57 @@ WITH token_user AS (
 SELECT * FROM users WHERE id = (SELECT user_id FROM token_user);
 
 -- name: UpdateUser :exec
-UPDATE users SET email = $1, hashed_password = $2, updated_at = $3 WHERE id = $4;
+UPDATE users SET email = $1, hashed_password = $2, updated_at = $3,
+    email_verified_at = CASE WHEN email = $1 THEN email_verified_at ELSE NULL END
+WHERE id = $4;
+
+-- name: UpdateUserPassword :exec
+UPDATE users SET hashed_password = $1, updated_at = $2 WHERE id = $3;
+
+-- name: UpdateUserPasswordIfHash :exec
+UPDATE users SET hashed_password = sqlc.arg(new_hash), updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id) AND hashed_password = sqlc.arg(old_hash);
+
+-- name: MarkUserEmailVerified :execrows
+UPDATE users SET email_verified_at = $1, updated_at = $2 WHERE id = $3 AND email = $4;
+
+-- name: SetUserTOTPSecret :exec
+UPDATE users SET totp_secret = $1, totp_enabled_at = NULL, updated_at = $2 WHERE id = $3;
+
+-- name: EnableUserTOTP :exec
+UPDATE users SET totp_enabled_at = $1, totp_last_step = $2, updated_at = $3 WHERE id = $4;
 
--- name: UpgradeToPremium :exec
-UPDATE users SET is_premium = TRUE WHERE id = $1;
+-- name: UpdateUserTOTPLastStep :execrows
+UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1;
+
+-- name: SetUserRole :exec
+UPDATE users SET role = $1, updated_at = $2 WHERE id = $3;
+
+-- name: PromoteFirstAdmin :execrows
+UPDATE users SET role = 'admin', updated_at = $1
+WHERE id = $2 AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin');
+
+-- name: GetPrincipalUser :one
+SELECT users.id, users.role, users.email_verified_at, EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id AND subscriptions.status <> 'expired'
+        AND COALESCE(subscriptions.grace_period_end, subscriptions.current_period_end) > sqlc.arg(now)
+) AS is_premium
+FROM users WHERE users.id = sqlc.arg(id);
+
+-- name: AddUserFollowCounts :exec
+UPDATE users SET
+    follower_count = follower_count + CASE WHEN id = sqlc.arg(followee_id) THEN sqlc.arg(delta)::integer ELSE 0 END,
+    following_count = following_count + CASE WHEN id = sqlc.arg(follower_id) THEN sqlc.arg(delta)::integer ELSE 0 END
+WHERE id IN (sqlc.arg(followee_id), sqlc.arg(follower_id));
+
+-- name: UpdateUserProfile :one
+UPDATE users SET
+    handle = COALESCE(sqlc.narg(handle), handle),
+    display_name = COALESCE(sqlc.narg(display_name), display_name),
+    bio = COALESCE(sqlc.narg(bio), bio),
+    website = COALESCE(sqlc.narg(website), website),
+    location = COALESCE(sqlc.narg(location), location),
+    updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
@@ -0,0 +1,53 @@
+-- name: RecordWebhookEvent :execrows
+INSERT INTO webhook_events (id, event_type, received_at, payload, status, next_attempt_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    'pending',
+    $3
+)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: ClaimWebhookEvents :many
+UPDATE webhook_events
+SET attempts = attempts + 1, next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id FROM webhook_events
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)
+    ORDER BY received_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET status = 'processed', processed_at = $1, last_error = NULL
+WHERE id = $2;
+
+-- name: RetryWebhookEvent :exec
+UPDATE webhook_events
+SET next_attempt_at = $1, last_error = $2
+WHERE id = $3;
+
+-- name: MarkWebhookEventDead :exec
+UPDATE webhook_events
+SET status = 'dead', last_error = $1
+WHERE id = $2;
+
+-- name: ListWebhookEventsByStatus :many
+SELECT * FROM webhook_events
+WHERE status = $1
+ORDER BY received_at DESC
+LIMIT $2;
+
+-- name: ReplayWebhookEvent :execrows
+UPDATE webhook_events
+SET status = 'pending', attempts = 0, next_attempt_at = $1
+WHERE id = $2 AND status = 'dead';
@@ -0,0 +1,14 @@
+-- name: CreateZingerRevision :exec
+INSERT INTO zinger_revisions (zinger_id, revision, body, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+);
+
+-- name: ListZingerRevisions :many
+SELECT * FROM zinger_revisions WHERE zinger_id = $1 ORDER BY revision;
+
+-- name: DeleteZingerRevisions :exec
+DELETE FROM zinger_revisions WHERE zinger_id = $1;
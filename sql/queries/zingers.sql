@@ -1,22 +1,129 @@
 -- name: CreateZinger :one
-INSERT INTO zingers (id, created_at, updated_at, body, user_id)
+INSERT INTO zingers (id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, quote_of_id)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7,
+    $8
 )
 RETURNING *;
 
--- name: GetAllZingers :many
-SELECT * FROM zingers ORDER BY created_at ASC;
+-- name: CreateRezing :one
+INSERT INTO zingers (id, created_at, updated_at, body, user_id, conversation_id, rezing_of_id)
+VALUES (
+    $1,
+    $2,
+    $2,
+    '',
+    $3,
+    $1,
+    $4
+)
+ON CONFLICT (user_id, rezing_of_id) WHERE rezing_of_id IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: DeleteRezing :one
+DELETE FROM zingers WHERE user_id = $1 AND rezing_of_id = $2
+RETURNING id;
+
+-- name: DeleteRezingsOf :exec
+DELETE FROM zingers WHERE rezing_of_id = $1;
+
+-- name: GetZingersByIds :many
+SELECT * FROM zingers WHERE id = ANY(sqlc.arg(ids)::uuid[]);
 
--- name: GetZingersByUser :many
-SELECT * FROM zingers WHERE user_id = $1 ORDER BY created_at ASC;
+-- name: ListZingersAsc :many
+SELECT * FROM zingers
+WHERE deleted_at IS NULL
+    AND (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+    AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+    AND (sqlc.narg(after_created_at)::timestamp IS NULL OR (created_at, id) > (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(row_limit);
+
+-- name: ListZingersDesc :many
+SELECT * FROM zingers
+WHERE deleted_at IS NULL
+    AND (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+    AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+    AND (sqlc.narg(after_created_at)::timestamp IS NULL OR (created_at, id) < (sqlc.narg(after_created_at), sqlc.narg(after_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(row_limit);
 
 -- name: GetZingerById :one
 SELECT * FROM zingers WHERE id = $1;
 
 -- name: DeleteZingerById :exec
-DELETE FROM zingers WHERE id = $1;
\ No newline at end of file
+DELETE FROM zingers WHERE id = $1;
+
+-- name: GetZingerByIdForUpdate :one
+SELECT * FROM zingers WHERE id = $1 FOR UPDATE;
+
+-- name: TombstoneZinger :exec
+UPDATE zingers SET body = '', rezing_count = 0, deleted_at = $1, updated_at = $2 WHERE id = $3;
+
+-- name: EditZinger :one
+UPDATE zingers SET body = $1, updated_at = $2, edit_count = edit_count + 1 WHERE id = $3
+RETURNING *;
+
+-- name: IncrementReplyCount :execrows
+UPDATE zingers SET reply_count = reply_count + 1 WHERE id = $1 AND deleted_at IS NULL AND rezing_of_id IS NULL;
+
+-- name: DecrementReplyCount :exec
+UPDATE zingers SET reply_count = reply_count - 1 WHERE id = $1;
+
+-- name: IncrementLikeCount :execrows
+UPDATE zingers SET like_count = like_count + 1 WHERE id = $1 AND deleted_at IS NULL AND rezing_of_id IS NULL;
+
+-- name: DecrementLikeCount :exec
+UPDATE zingers SET like_count = like_count - 1 WHERE id = $1;
+
+-- name: IncrementRezingCount :execrows
+UPDATE zingers SET rezing_count = rezing_count + 1 WHERE id = $1 AND deleted_at IS NULL AND rezing_of_id IS NULL;
+
+-- name: DecrementRezingCount :exec
+UPDATE zingers SET rezing_count = rezing_count - 1 WHERE id = $1;
+
+-- name: IncrementQuoteCount :execrows
+UPDATE zingers SET quote_count = quote_count + 1 WHERE id = $1 AND deleted_at IS NULL AND rezing_of_id IS NULL;
+
+-- name: DecrementQuoteCount :exec
+UPDATE zingers SET quote_count = quote_count - 1 WHERE id = $1;
+
+-- name: GetZingerAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.*, 1 AS distance
+    FROM zingers child
+    JOIN zingers parent ON parent.id = child.in_reply_to_id
+    WHERE child.id = $1
+    UNION ALL
+    SELECT parent.*, ancestors.distance + 1
+    FROM ancestors
+    JOIN zingers parent ON parent.id = ancestors.in_reply_to_id
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out FROM ancestors
+ORDER BY distance DESC;
+
+-- name: GetZingerDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT zingers.*, 1 AS depth,
+        to_char(zingers.created_at, 'YYYYMMDDHH24MISSUS') || zingers.id::text AS path
+    FROM zingers
+    WHERE zingers.in_reply_to_id = sqlc.arg(zinger_id)
+    UNION ALL
+    SELECT zingers.*, descendants.depth + 1,
+        descendants.path || '/' || to_char(zingers.created_at, 'YYYYMMDDHH24MISSUS') || zingers.id::text
+    FROM descendants
+    JOIN zingers ON zingers.in_reply_to_id = descendants.id
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to_id, conversation_id, reply_count, deleted_at, like_count, rezing_of_id, quote_of_id, rezing_count, quote_count, edit_count, fanned_out, depth FROM descendants
+WHERE sqlc.narg(after_id)::uuid IS NULL
+    OR path COLLATE "C" > (SELECT path FROM descendants WHERE id = sqlc.narg(after_id)) COLLATE "C"
+ORDER BY path COLLATE "C"
+LIMIT sqlc.arg(row_limit);
@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE TABLE admin_audit_log (
+    id UUID PRIMARY KEY,
+    actor_id UUID,
+    action TEXT NOT NULL,
+    target_user_id UUID,
+    details JSONB NOT NULL DEFAULT '{}',
+    ip_address TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (actor_id)
+    REFERENCES users(id)
+    ON DELETE SET NULL,
+    FOREIGN KEY (target_user_id)
+    REFERENCES users(id)
+    ON DELETE SET NULL
+);
+
+CREATE INDEX admin_audit_log_created_at_idx ON admin_audit_log (created_at);
+
+-- +goose Down
+DROP TABLE admin_audit_log;
+ALTER TABLE users DROP COLUMN role;
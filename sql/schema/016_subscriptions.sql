@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL UNIQUE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'cancelled', 'expired')),
+    current_period_end TIMESTAMP NOT NULL,
+    grace_period_end TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- Existing premium users never had a billing period, give them one month.
+INSERT INTO subscriptions (id, user_id, plan, status, current_period_end, created_at, updated_at)
+SELECT gen_random_uuid(), id, 'red', 'active', NOW() + INTERVAL '30 days', NOW(), NOW()
+FROM users WHERE is_premium;
+
+ALTER TABLE users DROP COLUMN is_premium;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN is_premium BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE users SET is_premium = TRUE
+WHERE id IN (
+    SELECT user_id FROM subscriptions
+    WHERE status <> 'expired' AND COALESCE(grace_period_end, current_period_end) > NOW()
+);
+DROP TABLE subscriptions;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Webhook events become an inbox: deliveries are stored as received and
+-- processed by a worker, which retries failures until it gives up on them.
+ALTER TABLE webhook_events
+    ADD COLUMN payload TEXT NOT NULL DEFAULT '',
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'processed' CHECK (status IN ('pending', 'processed', 'dead')),
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN next_attempt_at TIMESTAMP,
+    ADD COLUMN last_error TEXT,
+    ADD COLUMN processed_at TIMESTAMP;
+
+-- Events received so far were processed on delivery.
+UPDATE webhook_events SET attempts = 1, next_attempt_at = received_at, processed_at = received_at;
+
+ALTER TABLE webhook_events
+    ALTER COLUMN payload DROP DEFAULT,
+    ALTER COLUMN status SET DEFAULT 'pending',
+    ALTER COLUMN next_attempt_at SET NOT NULL;
+
+CREATE INDEX webhook_events_pending_idx ON webhook_events (next_attempt_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP INDEX webhook_events_pending_idx;
+ALTER TABLE webhook_events
+    DROP COLUMN payload,
+    DROP COLUMN status,
+    DROP COLUMN attempts,
+    DROP COLUMN next_attempt_at,
+    DROP COLUMN last_error,
+    DROP COLUMN processed_at;
@@ -0,0 +1,7 @@
+-- +goose Up
+CREATE INDEX zingers_created_at_id_idx ON zingers (created_at, id);
+CREATE INDEX zingers_user_id_created_at_id_idx ON zingers (user_id, created_at, id);
+
+-- +goose Down
+DROP INDEX zingers_user_id_created_at_id_idx;
+DROP INDEX zingers_created_at_id_idx;
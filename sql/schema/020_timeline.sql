@@ -0,0 +1,31 @@
+-- +goose Up
+-- Home timelines, written when a zinger is posted (fan-out on write). Posts
+-- of authors with more followers than the fan-out threshold aren't copied
+-- here; they are merged in when a timeline is read.
+CREATE TABLE timeline_entries (
+    user_id UUID NOT NULL,
+    zinger_id UUID NOT NULL,
+    author_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, zinger_id),
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (zinger_id)
+    REFERENCES zingers(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX timeline_entries_user_id_created_at_idx ON timeline_entries (user_id, created_at, zinger_id);
+CREATE INDEX timeline_entries_user_id_author_id_idx ON timeline_entries (user_id, author_id);
+
+INSERT INTO timeline_entries (user_id, zinger_id, author_id, created_at)
+SELECT user_id, id, user_id, created_at FROM zingers;
+
+INSERT INTO timeline_entries (user_id, zinger_id, author_id, created_at)
+SELECT follows.follower_id, zingers.id, zingers.user_id, zingers.created_at
+FROM follows
+JOIN zingers ON zingers.user_id = follows.followee_id;
+
+-- +goose Down
+DROP TABLE timeline_entries;
@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN handle TEXT,
+    ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+    ADD COLUMN website TEXT NOT NULL DEFAULT '',
+    ADD COLUMN location TEXT NOT NULL DEFAULT '';
+
+-- Existing users get the same kind of handle as new users who don't pick
+-- one, and can change it.
+UPDATE users SET handle = 'user_' || SUBSTRING(REPLACE(id::text, '-', '') FROM 1 FOR 12);
+
+ALTER TABLE users ALTER COLUMN handle SET NOT NULL;
+
+-- Handles are unique regardless of case, but keep the case they were
+-- chosen in.
+CREATE UNIQUE INDEX users_handle_lower_idx ON users (LOWER(handle));
+
+-- +goose Down
+DROP INDEX users_handle_lower_idx;
+ALTER TABLE users
+    DROP COLUMN handle,
+    DROP COLUMN display_name,
+    DROP COLUMN bio,
+    DROP COLUMN website,
+    DROP COLUMN location;
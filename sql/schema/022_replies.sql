@@ -0,0 +1,24 @@
+-- +goose Up
+-- Every zinger belongs to a conversation, named after the zinger that
+-- started it. Zingers that were replied to aren't deleted, only emptied and
+-- marked deleted, so the rest of the thread keeps its place.
+ALTER TABLE zingers
+    ADD COLUMN in_reply_to_id UUID REFERENCES zingers(id),
+    ADD COLUMN conversation_id UUID,
+    ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN deleted_at TIMESTAMP;
+
+UPDATE zingers SET conversation_id = id;
+
+ALTER TABLE zingers ALTER COLUMN conversation_id SET NOT NULL;
+
+CREATE INDEX zingers_in_reply_to_id_idx ON zingers (in_reply_to_id, created_at, id);
+CREATE INDEX zingers_conversation_id_idx ON zingers (conversation_id);
+
+-- +goose Down
+DELETE FROM zingers WHERE deleted_at IS NOT NULL;
+ALTER TABLE zingers
+    DROP COLUMN in_reply_to_id,
+    DROP COLUMN conversation_id,
+    DROP COLUMN reply_count,
+    DROP COLUMN deleted_at;
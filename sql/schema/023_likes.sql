@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE likes (
+    user_id UUID NOT NULL,
+    zinger_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, zinger_id),
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (zinger_id)
+    REFERENCES zingers(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX likes_zinger_id_created_at_idx ON likes (zinger_id, created_at, user_id);
+CREATE INDEX likes_user_id_created_at_idx ON likes (user_id, created_at, zinger_id);
+
+-- Kept in step with likes in the same transaction, so reads don't count.
+ALTER TABLE zingers ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE zingers DROP COLUMN like_count;
+DROP TABLE likes;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- A rezing is a zinger with no body of its own that shares another one,
+-- a quote has a body and points at the zinger it quotes. Rezings go away
+-- with the zinger they share; quoted zingers are only emptied, like the
+-- ones that were replied to.
+ALTER TABLE zingers
+    ADD COLUMN rezing_of_id UUID REFERENCES zingers(id) ON DELETE CASCADE,
+    ADD COLUMN quote_of_id UUID REFERENCES zingers(id),
+    ADD COLUMN rezing_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN quote_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX zingers_user_id_rezing_of_id_idx ON zingers (user_id, rezing_of_id) WHERE rezing_of_id IS NOT NULL;
+CREATE INDEX zingers_rezing_of_id_idx ON zingers (rezing_of_id) WHERE rezing_of_id IS NOT NULL;
+CREATE INDEX zingers_quote_of_id_idx ON zingers (quote_of_id) WHERE quote_of_id IS NOT NULL;
+
+-- +goose Down
+DELETE FROM zingers WHERE rezing_of_id IS NOT NULL;
+ALTER TABLE zingers
+    DROP COLUMN rezing_of_id,
+    DROP COLUMN quote_of_id,
+    DROP COLUMN rezing_count,
+    DROP COLUMN quote_count;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Bodies zingers had before they were edited, numbered from 0 for the body
+-- they were posted with. created_at is when the body was written.
+CREATE TABLE zinger_revisions (
+    zinger_id UUID NOT NULL,
+    revision INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (zinger_id, revision),
+    FOREIGN KEY (zinger_id)
+    REFERENCES zingers(id)
+    ON DELETE CASCADE
+);
+
+ALTER TABLE zingers ADD COLUMN edit_count INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE zingers DROP COLUMN edit_count;
+DROP TABLE zinger_revisions;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Whether a zinger was copied into its author's followers' timelines when it
+-- was posted, decided by their follower count then. Zingers that weren't are
+-- merged into timelines when they are read, whatever the author's follower
+-- count is now.
+ALTER TABLE zingers ADD COLUMN fanned_out BOOLEAN NOT NULL DEFAULT true;
+
+-- Zingers missing from the timeline of any current follower weren't copied.
+UPDATE zingers SET fanned_out = false
+WHERE EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.followee_id = zingers.user_id
+        AND NOT EXISTS (
+            SELECT 1 FROM timeline_entries
+            WHERE timeline_entries.user_id = follows.follower_id AND timeline_entries.zinger_id = zingers.id
+        )
+);
+
+CREATE INDEX zingers_user_id_not_fanned_out_idx ON zingers (user_id, created_at, id) WHERE NOT fanned_out;
+
+-- +goose Down
+ALTER TABLE zingers DROP COLUMN fanned_out;
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+	"github.com/google/uuid"
+)
+
+// defaultPlan is the plan of upgrades that don't name one.
+const defaultPlan = "red"
+
+// defaultBillingPeriod is assumed for upgrades that don't say when the first
+// period ends.
+const defaultBillingPeriod = time.Duration(30*24) * time.Hour
+
+// errMalformedEvent marks webhook events whose data can't be used. ZingPay
+// won't send them any differently on a retry.
+var errMalformedEvent = errors.New("malformed event data")
+
+// applyZingPayEvent updates the subscription an event is about. Subscriptions
+// grant premium until their current period ends; a failed payment extends that
+// by the grace period while ZingPay retries the charge, a cancellation lets
+// the paid period run out and a downgrade ends premium at once. Events for
+// users without a subscription and unknown event types are ignored.
+func (cfg *apiConfig) applyZingPayEvent(ctx context.Context, q *database.Queries, event zingpay.Event) error {
+	var data zingpay.SubscriptionData
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.UserID == uuid.Nil {
+		return errMalformedEvent
+	}
+	now := time.Now().UTC()
+
+	var rows int64
+	var err error
+	switch event.Type {
+	case zingpay.EventUserUpgraded:
+		if data.Plan == "" {
+			data.Plan = defaultPlan
+		}
+		if data.CurrentPeriodEnd.IsZero() {
+			data.CurrentPeriodEnd = now.Add(defaultBillingPeriod)
+		}
+		_, err = q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{ID: uuid.New(), UserID: data.UserID, Plan: data.Plan, CurrentPeriodEnd: data.CurrentPeriodEnd.UTC(), CreatedAt: now})
+		rows = 1
+	case zingpay.EventSubscriptionRenewed:
+		if data.CurrentPeriodEnd.IsZero() {
+			return errMalformedEvent
+		}
+		rows, err = q.RenewSubscription(ctx, database.RenewSubscriptionParams{CurrentPeriodEnd: data.CurrentPeriodEnd.UTC(), UpdatedAt: now, UserID: data.UserID})
+	case zingpay.EventSubscriptionCancelled:
+		rows, err = q.CancelSubscription(ctx, database.CancelSubscriptionParams{UpdatedAt: now, UserID: data.UserID})
+	case zingpay.EventPaymentFailed:
+		subscription, err := q.GetSubscriptionByUser(ctx, data.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// The grace period never cuts short a period that is already paid.
+		graceEnd := now
+		if subscription.CurrentPeriodEnd.After(graceEnd) {
+			graceEnd = subscription.CurrentPeriodEnd
+		}
+		graceEnd = graceEnd.Add(cfg.subscription_grace_period)
+		rows, err = q.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{GracePeriodEnd: sql.NullTime{Time: graceEnd, Valid: true}, UpdatedAt: now, UserID: data.UserID})
+		if err != nil {
+			return err
+		}
+	case zingpay.EventUserDowngraded:
+		rows, err = q.ExpireSubscription(ctx, database.ExpireSubscriptionParams{Now: now, UserID: data.UserID})
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		log.Printf("Ignoring ZingPay event %s (%s): no subscription to update for user %s", event.ID, event.Type, data.UserID)
+	}
+	return nil
+}
+
+// runSubscriptionExpiry marks subscriptions whose paid and grace periods are
+// over as expired, every interval until ctx is done. Premium checks already
+// look at the dates, this keeps the stored status in line with them.
+func (cfg *apiConfig) runSubscriptionExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		rows, err := cfg.dbq.ExpireLapsedSubscriptions(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("Error expiring subscriptions: %s", err)
+		} else if rows > 0 {
+			log.Printf("Expired %d lapsed subscriptions", rows)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// billingPlans are the plans users can check out.
+var billingPlans = []string{defaultPlan}
+
+// requireBilling responds with 503 and returns false when no ZingPay API key
+// is configured.
+func (cfg *apiConfig) requireBilling(w http.ResponseWriter) bool {
+	if cfg.zingpay == nil {
+		respondWithError(w, 503, "Billing is not configured")
+		return false
+	}
+	return true
+}
+
+// respondWithZingPayError reports a failed ZingPay API call as a bad gateway,
+// the details only go to the log.
+func respondWithZingPayError(w http.ResponseWriter, err error) {
+	log.Printf("ZingPay API call failed: %s", err)
+	respondWithError(w, 502, "ZingPay is unavailable, try again later")
+}
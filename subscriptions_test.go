@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+	"github.com/bsuvonov/zingzing/internal/zingpay/zingpaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	user, _ := createTestUser(t, cfg)
+	url := api.URL + "/api/zingpay/webhooks"
+
+	send := func(eventType string, data zingpay.SubscriptionData) {
+		t.Helper()
+		data.UserID = user.ID
+		status, err := zingpaytest.Deliver(url, zingpaytest.NewEvent(eventType, data), testWebhookSecret)
+		require.NoError(t, err)
+		require.Equal(t, 204, status)
+		_, err = cfg.processWebhookEvents(context.Background())
+		require.NoError(t, err)
+	}
+	subscription := func() database.Subscription {
+		t.Helper()
+		got, err := cfg.dbq.GetSubscriptionByUser(context.Background(), user.ID)
+		require.NoError(t, err)
+		return got
+	}
+	isPremiumAt := func(now time.Time) bool {
+		t.Helper()
+		got, err := cfg.dbq.IsUserPremium(context.Background(), database.IsUserPremiumParams{UserID: user.ID, Now: now})
+		require.NoError(t, err)
+		return got
+	}
+
+	periodEnd := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	send(zingpay.EventUserUpgraded, zingpay.SubscriptionData{Plan: "red", CurrentPeriodEnd: periodEnd})
+	assert.Equal(t, "active", subscription().Status)
+	assert.True(t, isPremiumAt(time.Now().UTC()))
+	assert.False(t, isPremiumAt(periodEnd.Add(time.Minute)))
+
+	send(zingpay.EventPaymentFailed, zingpay.SubscriptionData{})
+	got := subscription()
+	assert.Equal(t, "past_due", got.Status)
+	require.True(t, got.GracePeriodEnd.Valid)
+	assert.WithinDuration(t, periodEnd.Add(cfg.subscription_grace_period), got.GracePeriodEnd.Time, time.Second)
+	assert.True(t, isPremiumAt(periodEnd.Add(time.Minute)), "grace period")
+
+	renewedEnd := periodEnd.Add(30 * 24 * time.Hour)
+	send(zingpay.EventSubscriptionRenewed, zingpay.SubscriptionData{CurrentPeriodEnd: renewedEnd})
+	got = subscription()
+	assert.Equal(t, "active", got.Status)
+	assert.False(t, got.GracePeriodEnd.Valid)
+
+	send(zingpay.EventSubscriptionCancelled, zingpay.SubscriptionData{})
+	assert.Equal(t, "cancelled", subscription().Status)
+	assert.True(t, isPremiumAt(time.Now().UTC()), "paid period runs out")
+
+	rows, err := cfg.dbq.ExpireLapsedSubscriptions(context.Background(), renewedEnd.Add(time.Minute))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, rows, int64(1))
+	assert.Equal(t, "expired", subscription().Status)
+	assert.False(t, isPremiumAt(time.Now().UTC()))
+
+	send(zingpay.EventUserUpgraded, zingpay.SubscriptionData{})
+	assert.True(t, isPremiumAt(time.Now().UTC()), "resubscribed")
+
+	send(zingpay.EventUserDowngraded, zingpay.SubscriptionData{})
+	assert.Equal(t, "expired", subscription().Status)
+	assert.False(t, isPremiumAt(time.Now().UTC()))
+}
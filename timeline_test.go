@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHomeTimeline(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	cfg.timeline_fanout_threshold = 2
+	_, aliceToken := createTestUser(t, cfg)
+	bob, bobToken := createTestUser(t, cfg)
+	celeb, celebToken := createTestUser(t, cfg)
+	_, carolToken := createTestUser(t, cfg)
+
+	post := func(token, body string) uuid.UUID {
+		t.Helper()
+		var zinger struct {
+			ID uuid.UUID `json:"id"`
+		}
+		resp := doJSON(t, "POST", api.URL+"/api/zingers", token, map[string]string{"body": body}, &zinger)
+		require.Equal(t, 201, resp.StatusCode)
+		return zinger.ID
+	}
+	follow := func(token string, userID uuid.UUID) {
+		t.Helper()
+		resp := doJSON(t, "POST", api.URL+"/api/users/"+userID.String()+"/follow", token, nil, nil)
+		require.Equal(t, 200, resp.StatusCode)
+	}
+	timeline := func(token string, limit string) []uuid.UUID {
+		t.Helper()
+		ids := []uuid.UUID{}
+		query := "?limit=" + limit
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 10)
+			var page zingersPage
+			resp := doJSON(t, "GET", api.URL+"/api/timeline/home"+query, token, nil, &page)
+			require.Equal(t, 200, resp.StatusCode)
+			for _, z := range page.Zingers {
+				ids = append(ids, z.ID)
+			}
+			if page.NextCursor == nil {
+				return ids
+			}
+			query = "?limit=" + limit + "&cursor=" + *page.NextCursor
+		}
+	}
+
+	// Bob's earlier zinger is backfilled when Alice follows him. The
+	// celebrity has as many followers as the threshold, their zingers are
+	// only merged in on read.
+	bobEarly := post(bobToken, "before alice followed")
+	follow(aliceToken, bob.ID)
+	follow(aliceToken, celeb.ID)
+	follow(carolToken, celeb.ID)
+	own := post(aliceToken, "my own")
+	celebPost := post(celebToken, "hello fans")
+	bobLate := post(bobToken, "after alice followed")
+	post(carolToken, "alice doesn't follow carol")
+
+	want := []uuid.UUID{bobLate, celebPost, own, bobEarly}
+	assert.Equal(t, want, timeline(aliceToken, "100"))
+	assert.Equal(t, want, timeline(aliceToken, "1"), "pages add up")
+	assert.Equal(t, []uuid.UUID{bobLate, bobEarly}, timeline(bobToken, "100"))
+
+	resp := doJSON(t, "DELETE", api.URL+"/api/users/"+bob.ID.String()+"/follow", aliceToken, nil, nil)
+	require.Equal(t, 204, resp.StatusCode)
+	assert.Equal(t, []uuid.UUID{celebPost, own}, timeline(aliceToken, "100"), "unfollowed authors leave the timeline")
+
+	// Dropping below the threshold doesn't lose what the celebrity posted
+	// while they were above it, and their new zingers are copied again.
+	resp = doJSON(t, "DELETE", api.URL+"/api/users/"+celeb.ID.String()+"/follow", carolToken, nil, nil)
+	require.Equal(t, 204, resp.StatusCode)
+	celebLate := post(celebToken, "fewer fans now")
+	assert.Equal(t, []uuid.UUID{celebLate, celebPost, own}, timeline(aliceToken, "100"))
+
+	resp = doJSON(t, "GET", api.URL+"/api/timeline/home", "", nil, nil)
+	assert.Equal(t, 401, resp.StatusCode)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactor(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	user, token := createTestUser(t, cfg)
+	t.Cleanup(func() {
+		cfg.dbq.ClearLoginThrottle(context.Background(), "2fa:"+user.ID.String())
+		cfg.dbq.ClearLoginThrottle(context.Background(), "ip:127.0.0.1")
+	})
+
+	resp := doJSON(t, "POST", api.URL+"/api/users/2fa/confirm", token, map[string]string{"code": "123456"}, nil)
+	assert.Equal(t, 400, resp.StatusCode, "confirm before enroll")
+
+	var enrollment struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+	resp = doJSON(t, "POST", api.URL+"/api/users/2fa/enroll", token, nil, &enrollment)
+	require.Equal(t, 200, resp.StatusCode)
+	require.NotEmpty(t, enrollment.Secret)
+	assert.Contains(t, enrollment.OtpauthURI, "secret="+enrollment.Secret)
+
+	resp = doJSON(t, "POST", api.URL+"/api/users/2fa/confirm", token, map[string]string{"code": "not-a-code"}, nil)
+	assert.Equal(t, 400, resp.StatusCode)
+	code, err := auth.TOTPCode(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	resp = doJSON(t, "POST", api.URL+"/api/users/2fa/confirm", token, map[string]string{"code": code}, &confirmed)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Len(t, confirmed.RecoveryCodes, recoveryCodeCount)
+	resp = doJSON(t, "POST", api.URL+"/api/users/2fa/enroll", token, nil, nil)
+	assert.Equal(t, 409, resp.StatusCode, "already enabled")
+
+	challenge := func() string {
+		t.Helper()
+		var login struct {
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			ChallengeToken    string `json:"challenge_token"`
+			Token             string `json:"token"`
+		}
+		resp := doJSON(t, "POST", api.URL+"/api/login", "", map[string]string{"email": user.Email, "password": "password"}, &login)
+		require.Equal(t, 200, resp.StatusCode)
+		require.True(t, login.TwoFactorRequired)
+		require.Empty(t, login.Token, "no access token before the second factor")
+		return login.ChallengeToken
+	}
+	secondFactor := func(challengeToken, code string) int {
+		t.Helper()
+		return doJSON(t, "POST", api.URL+"/api/login/2fa", "", map[string]string{"challenge_token": challengeToken, "code": code}, nil).StatusCode
+	}
+
+	t.Run("TOTP", func(t *testing.T) {
+		assert.Equal(t, 401, secondFactor(challenge(), code), "the code used to confirm is spent")
+		next, err := auth.TOTPCode(enrollment.Secret, time.Now().Add(30*time.Second))
+		require.NoError(t, err)
+		var tokens refreshResponse
+		resp := doJSON(t, "POST", api.URL+"/api/login/2fa", "", map[string]string{"challenge_token": challenge(), "code": next}, &tokens)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.NotEmpty(t, tokens.Token)
+		assert.NotEmpty(t, tokens.RefreshToken)
+		assert.Equal(t, 401, secondFactor(challenge(), next), "replayed")
+	})
+
+	t.Run("Recovery code", func(t *testing.T) {
+		assert.Equal(t, 200, secondFactor(challenge(), confirmed.RecoveryCodes[0]))
+		assert.Equal(t, 401, secondFactor(challenge(), confirmed.RecoveryCodes[0]), "used up")
+		assert.Equal(t, 200, secondFactor(challenge(), confirmed.RecoveryCodes[1]))
+	})
+
+	t.Run("Challenge is not an access token", func(t *testing.T) {
+		resp := doJSON(t, "GET", api.URL+"/api/sessions", challenge(), nil, nil)
+		assert.Equal(t, 401, resp.StatusCode)
+		assert.Equal(t, 401, secondFactor(token, confirmed.RecoveryCodes[2]), "nor an access token a challenge")
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+)
+
+const (
+	// maxWebhookAttempts is how often an event is tried before it is moved
+	// to the dead letters.
+	maxWebhookAttempts = 8
+	// webhookRetryBaseDelay is the wait after the first failed attempt, it
+	// doubles with every further one up to webhookRetryMaxDelay.
+	webhookRetryBaseDelay = 30 * time.Second
+	webhookRetryMaxDelay  = time.Hour
+	// webhookLease is how long a claimed event is left alone before another
+	// worker may assume its worker died and try it again.
+	webhookLease     = time.Minute
+	webhookBatchSize = 20
+)
+
+// webhookRetryDelay is the wait before the next try of an event that failed
+// its attempts-th attempt.
+func webhookRetryDelay(attempts int32) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := int32(1); i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookRetryMaxDelay)
+}
+
+// notifyWebhookWorker wakes the worker up for a newly stored event, without
+// waiting if it is busy or isn't running.
+func (cfg *apiConfig) notifyWebhookWorker() {
+	select {
+	case cfg.webhook_wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// runWebhookWorker processes the webhook inbox whenever an event comes in,
+// and every interval for retries, until ctx is done.
+func (cfg *apiConfig) runWebhookWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := cfg.processWebhookEvents(ctx); err != nil {
+			log.Printf("Error processing webhook events: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.webhook_wakeup:
+		}
+	}
+}
+
+// processWebhookEvents works through the events that are due and returns how
+// many were tried. Several workers can share the inbox, each event is claimed
+// by one of them.
+func (cfg *apiConfig) processWebhookEvents(ctx context.Context) (int, error) {
+	tried := 0
+	for {
+		now := time.Now().UTC()
+		events, err := cfg.dbq.ClaimWebhookEvents(ctx, database.ClaimWebhookEventsParams{LeaseUntil: now.Add(webhookLease), Now: now, BatchSize: webhookBatchSize})
+		if err != nil {
+			return tried, err
+		}
+		if len(events) == 0 {
+			return tried, nil
+		}
+		for _, event := range events {
+			if err := cfg.processWebhookEvent(ctx, event); err != nil {
+				return tried, err
+			}
+			tried++
+		}
+	}
+}
+
+// processWebhookEvent applies a claimed event. A failure is scheduled for a
+// retry, unless retrying can't help or the event is out of attempts, in which
+// case it becomes a dead letter for an admin to look at. The returned error
+// is only about recording the outcome.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, stored database.WebhookEvent) error {
+	err := cfg.applyWebhookEvent(ctx, stored)
+	if err == nil {
+		return nil
+	}
+
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+	if errors.Is(err, errMalformedEvent) || stored.Attempts >= maxWebhookAttempts {
+		log.Printf("Giving up on ZingPay event %s after %d attempts: %s", stored.ID, stored.Attempts, err)
+		return cfg.dbq.MarkWebhookEventDead(ctx, database.MarkWebhookEventDeadParams{LastError: lastError, ID: stored.ID})
+	}
+	log.Printf("Processing ZingPay event %s failed, retrying: %s", stored.ID, err)
+	nextAttempt := time.Now().UTC().Add(webhookRetryDelay(stored.Attempts))
+	return cfg.dbq.RetryWebhookEvent(ctx, database.RetryWebhookEventParams{NextAttemptAt: nextAttempt, LastError: lastError, ID: stored.ID})
+}
+
+// applyWebhookEvent applies the event and marks it processed in one
+// transaction, so its effects happen exactly once.
+func (cfg *apiConfig) applyWebhookEvent(ctx context.Context, stored database.WebhookEvent) error {
+	event := zingpay.Event{}
+	if err := json.Unmarshal([]byte(stored.Payload), &event); err != nil {
+		return errMalformedEvent
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbq.WithTx(tx)
+
+	if err := cfg.applyZingPayEvent(ctx, qtx, event); err != nil {
+		return err
+	}
+	err = qtx.MarkWebhookEventProcessed(ctx, database.MarkWebhookEventProcessedParams{ProcessedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true}, ID: stored.ID})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/auth"
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/bsuvonov/zingzing/internal/zingpay"
+	"github.com/bsuvonov/zingzing/internal/zingpay/zingpaytest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, webhookRetryDelay(1))
+	assert.Equal(t, time.Minute, webhookRetryDelay(2))
+	assert.Equal(t, 4*time.Minute, webhookRetryDelay(4))
+	assert.Equal(t, time.Hour, webhookRetryDelay(maxWebhookAttempts))
+	assert.Equal(t, time.Hour, webhookRetryDelay(100))
+}
+
+func TestWebhookInbox(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	admin, _ := createTestUser(t, cfg)
+	require.NoError(t, cfg.dbq.SetUserRole(context.Background(), database.SetUserRoleParams{Role: auth.RoleAdmin, UpdatedAt: time.Now().UTC(), ID: admin.ID}))
+	adminToken, err := cfg.jwt_keys.MakeJWTWithRole(admin.ID, auth.RoleAdmin, time.Hour)
+	require.NoError(t, err)
+	user, _ := createTestUser(t, cfg)
+
+	deliver := func(event zingpay.Event) {
+		t.Helper()
+		t.Cleanup(func() { cfg.db.Exec("DELETE FROM webhook_events WHERE id = $1", event.ID) })
+		status, err := zingpaytest.Deliver(api.URL+"/api/zingpay/webhooks", event, testWebhookSecret)
+		require.NoError(t, err)
+		require.Equal(t, 204, status)
+		_, err = cfg.processWebhookEvents(context.Background())
+		require.NoError(t, err)
+	}
+	stored := func(id string) database.WebhookEvent {
+		t.Helper()
+		event, err := cfg.dbq.GetWebhookEvent(context.Background(), id)
+		require.NoError(t, err)
+		return event
+	}
+
+	t.Run("Processed", func(t *testing.T) {
+		event := zingpaytest.NewEvent(zingpay.EventUserUpgraded, zingpay.SubscriptionData{UserID: user.ID})
+		deliver(event)
+		got := stored(event.ID)
+		assert.Equal(t, "processed", got.Status)
+		assert.Equal(t, int32(1), got.Attempts)
+
+		resp := doJSON(t, "POST", api.URL+"/admin/webhooks/"+event.ID+"/replay", adminToken, nil, nil)
+		assert.Equal(t, 409, resp.StatusCode)
+	})
+
+	t.Run("Dead letter and replay", func(t *testing.T) {
+		// Nothing to retry about an event without a user.
+		event := zingpaytest.NewEvent(zingpay.EventUserDowngraded, map[string]string{"plan": "red"})
+		deliver(event)
+		got := stored(event.ID)
+		assert.Equal(t, "dead", got.Status)
+		assert.Equal(t, errMalformedEvent.Error(), got.LastError.String)
+
+		var dead []webhookEventResponse
+		resp := doJSON(t, "GET", api.URL+"/admin/webhooks?status=dead&limit=100", adminToken, nil, &dead)
+		require.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, webhookEventIDs(dead), event.ID)
+
+		var replayed webhookEventResponse
+		resp = doJSON(t, "POST", api.URL+"/admin/webhooks/"+event.ID+"/replay", adminToken, nil, &replayed)
+		require.Equal(t, 202, resp.StatusCode)
+		assert.Equal(t, "pending", replayed.Status)
+		assert.Equal(t, int32(0), replayed.Attempts)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		// Upgrading a user that doesn't exist fails in the database, which
+		// is worth retrying.
+		event := zingpaytest.NewEvent(zingpay.EventUserUpgraded, zingpay.SubscriptionData{UserID: uuid.New()})
+		deliver(event)
+		got := stored(event.ID)
+		assert.Equal(t, "pending", got.Status)
+		assert.Equal(t, int32(1), got.Attempts)
+		assert.True(t, got.LastError.Valid)
+		assert.WithinDuration(t, time.Now().UTC().Add(webhookRetryBaseDelay), got.NextAttemptAt, 5*time.Second)
+
+		_, err := cfg.db.Exec("UPDATE webhook_events SET attempts = $1, next_attempt_at = $2 WHERE id = $3", maxWebhookAttempts-1, time.Now().UTC(), event.ID)
+		require.NoError(t, err)
+		_, err = cfg.processWebhookEvents(context.Background())
+		require.NoError(t, err)
+		got = stored(event.ID)
+		assert.Equal(t, "dead", got.Status, "out of attempts")
+		assert.Equal(t, int32(maxWebhookAttempts), got.Attempts)
+	})
+
+	t.Run("Unknown event", func(t *testing.T) {
+		resp := doJSON(t, "POST", api.URL+"/admin/webhooks/evt_missing/replay", adminToken, nil, nil)
+		assert.Equal(t, 404, resp.StatusCode)
+	})
+}
+
+func webhookEventIDs(events []webhookEventResponse) []string {
+	ids := []string{}
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/bsuvonov/zingzing/internal/zingpay/zingpaytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZingPayWebhook(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	user, _ := createTestUser(t, cfg)
+	url := api.URL + "/api/zingpay/webhooks"
+
+	isPremium := func() bool {
+		got, err := cfg.dbq.IsUserPremium(context.Background(), database.IsUserPremiumParams{UserID: user.ID, Now: time.Now().UTC()})
+		require.NoError(t, err)
+		return got
+	}
+	process := func() {
+		_, err := cfg.processWebhookEvents(context.Background())
+		require.NoError(t, err)
+	}
+	deliver := func(req *http.Request, err error) int {
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	upgrade := zingpaytest.NewEvent("user.upgraded", map[string]string{"user_id": user.ID.String()})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		req, err := zingpaytest.NewRequest(url, upgrade, time.Now())
+		assert.Equal(t, 401, deliver(req, err))
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		assert.Equal(t, 401, deliver(zingpaytest.NewRequest(url, upgrade, time.Now(), "whsec_guess")))
+		assert.False(t, isPremium())
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		assert.Equal(t, 401, deliver(zingpaytest.NewRequest(url, upgrade, time.Now().Add(-time.Hour), testWebhookSecret)))
+		assert.False(t, isPremium())
+	})
+
+	t.Run("Signed", func(t *testing.T) {
+		status, err := zingpaytest.Deliver(url, upgrade, testWebhookSecret)
+		require.NoError(t, err)
+		assert.Equal(t, 204, status)
+		assert.False(t, isPremium(), "processed asynchronously")
+		process()
+		assert.True(t, isPremium())
+	})
+
+	t.Run("Duplicate delivery", func(t *testing.T) {
+		_, err := cfg.db.Exec("DELETE FROM subscriptions WHERE user_id = $1", user.ID)
+		require.NoError(t, err)
+
+		status, err := zingpaytest.Deliver(url, upgrade, testWebhookSecret)
+		require.NoError(t, err)
+		assert.Equal(t, 204, status, "acknowledged")
+		process()
+		assert.False(t, isPremium(), "but not processed again")
+	})
+
+	t.Run("Previous secret", func(t *testing.T) {
+		again := zingpaytest.NewEvent("user.upgraded", map[string]string{"user_id": user.ID.String()})
+		status, err := zingpaytest.Deliver(url, again, testPreviousWebhookSecret)
+		require.NoError(t, err)
+		assert.Equal(t, 204, status)
+		process()
+		assert.True(t, isPremium())
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/rivo/uniseg"
+)
+
+// urlPattern finds the links in a zinger, without the punctuation that
+// usually follows them in a sentence.
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S*[^\s.,!?;:'")\]]`)
+
+// zingerLengthPolicy is how long zinger bodies may be, in characters as
+// people see them. Every link counts as URLLength characters however long it
+// is.
+type zingerLengthPolicy struct {
+	MaxLength        int
+	PremiumMaxLength int
+	URLLength        int
+}
+
+func (p zingerLengthPolicy) maxLength(premium bool) int {
+	if premium {
+		return p.PremiumMaxLength
+	}
+	return p.MaxLength
+}
+
+// length returns the length of body as it counts against the limit.
+func (p zingerLengthPolicy) length(body string) int {
+	n, end := 0, 0
+	for _, loc := range urlPattern.FindAllStringIndex(body, -1) {
+		n += uniseg.GraphemeClusterCount(body[end:loc[0]]) + p.URLLength
+		end = loc[1]
+	}
+	return n + uniseg.GraphemeClusterCount(body[end:])
+}
+
+// checkZingerBody responds with a 400 that tells the client how long body is
+// and how long it may be, and returns false, when it is empty or too long.
+func (cfg *apiConfig) checkZingerBody(w http.ResponseWriter, body string, premium bool) bool {
+	type returnVals struct {
+		Err       string `json:"error"`
+		Code      string `json:"code"`
+		Length    int    `json:"length"`
+		MaxLength int    `json:"max_length"`
+	}
+	length, maxLength := cfg.zinger_length.length(body), cfg.zinger_length.maxLength(premium)
+	switch {
+	case strings.TrimSpace(body) == "":
+		respondWithJSON(w, 400, returnVals{Err: "zinger can't be empty", Code: "zinger_empty", Length: length, MaxLength: maxLength})
+		return false
+	case length > maxLength:
+		respondWithJSON(w, 400, returnVals{Err: "zinger is too long", Code: "zinger_too_long", Length: length, MaxLength: maxLength})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZingerLength(t *testing.T) {
+	policy := zingerLengthPolicy{MaxLength: 280, PremiumMaxLength: 1000, URLLength: 23}
+	tests := []struct {
+		body string
+		want int
+	}{
+		{"zing", 4},
+		{"\U0001F468\u200d\U0001F469\u200d\U0001F467 family", 8},
+		{"see https://example.com/a/very/long/path?with=query", 4 + 23},
+		{"(www.example.com), then http://x.io.", 1 + 23 + 8 + 23 + 1},
+		{"https://a.io https://b.io", 23 + 1 + 23},
+		{"http:// isn't a link", 20},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, policy.length(tt.body), tt.body)
+	}
+	assert.Equal(t, 280, policy.maxLength(false))
+	assert.Equal(t, 1000, policy.maxLength(true))
+}
+
+func TestZingerLengthLimits(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	cfg.zinger_length = zingerLengthPolicy{MaxLength: 10, PremiumMaxLength: 20, URLLength: 5}
+	_, token := createTestUser(t, cfg)
+
+	var limits struct {
+		MaxLength        int `json:"max_length"`
+		PremiumMaxLength int `json:"premium_max_length"`
+		URLLength        int `json:"url_length"`
+	}
+	resp := doJSON(t, "GET", api.URL+"/api/zingers/limits", "", nil, &limits)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, 10, limits.MaxLength)
+	assert.Equal(t, 20, limits.PremiumMaxLength)
+	assert.Equal(t, 5, limits.URLLength)
+
+	resp = doJSON(t, "POST", api.URL+"/api/zingers", token, map[string]string{"body": strings.Repeat("\U0001F600", 10)}, nil)
+	assert.Equal(t, 201, resp.StatusCode, "emoji count once")
+	resp = doJSON(t, "POST", api.URL+"/api/zingers", token, map[string]string{"body": "https://example.com/" + strings.Repeat("x", 100)}, nil)
+	assert.Equal(t, 201, resp.StatusCode, "links count as url_length")
+
+	var rejected struct {
+		Err       string `json:"error"`
+		Code      string `json:"code"`
+		Length    int    `json:"length"`
+		MaxLength int    `json:"max_length"`
+	}
+	resp = doJSON(t, "POST", api.URL+"/api/zingers", token, map[string]string{"body": "eleven char"}, &rejected)
+	require.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "zinger_too_long", rejected.Code)
+	assert.Equal(t, 11, rejected.Length)
+	assert.Equal(t, 10, rejected.MaxLength)
+
+	resp = doJSON(t, "POST", api.URL+"/api/zingers", token, map[string]string{"body": "  "}, &rejected)
+	require.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "zinger_empty", rejected.Code)
+}
+
+// TestZingerLengthGraphemeBreaks checks the counting against the grapheme
+// cluster boundaries of testdata/GraphemeBreakTest.txt, the Unicode
+// conformance test.
+func TestZingerLengthGraphemeBreaks(t *testing.T) {
+	data, err := os.ReadFile("testdata/GraphemeBreakTest.txt")
+	require.NoError(t, err)
+	policy := zingerLengthPolicy{URLLength: 23}
+	for i, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var body strings.Builder
+		clusters := 0
+		for _, field := range strings.Fields(line) {
+			switch field {
+			case "÷":
+				clusters++
+			case "×":
+			default:
+				r, err := strconv.ParseUint(field, 16, 32)
+				require.NoError(t, err, "line %d", i+1)
+				body.WriteRune(rune(r))
+			}
+		}
+		// The boundaries before the first and after the last character
+		// don't separate clusters.
+		assert.Equal(t, clusters-1, policy.length(body.String()), "line %d: %s", i+1, line)
+	}
+}
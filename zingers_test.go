@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bsuvonov/zingzing/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zingersPage is the response of GET /api/zingers.
+type zingersPage struct {
+	Zingers []struct {
+		ID        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+	} `json:"zingers"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+func TestZingersPagination(t *testing.T) {
+	cfg, api := newTestAPI(t)
+	user, _ := createTestUser(t, cfg)
+
+	// Five zingers a minute apart, two of them at the same time to check
+	// ties are broken by ID.
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := []time.Time{start, start.Add(time.Minute), start.Add(time.Minute), start.Add(2 * time.Minute), start.Add(3 * time.Minute)}
+	for _, at := range created {
+		_, err := cfg.dbq.CreateZinger(context.Background(), database.CreateZingerParams{ID: uuid.New(), CreatedAt: at, UpdatedAt: at, Body: "zing", UserID: user.ID})
+		require.NoError(t, err)
+	}
+
+	list := func(query url.Values) []uuid.UUID {
+		t.Helper()
+		query.Set("author_id", user.ID.String())
+		ids := []uuid.UUID{}
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 10)
+			var page zingersPage
+			resp := doJSON(t, "GET", api.URL+"/api/zingers?"+query.Encode(), "", nil, &page)
+			require.Equal(t, 200, resp.StatusCode)
+			for _, z := range page.Zingers {
+				ids = append(ids, z.ID)
+			}
+			if page.NextCursor == nil {
+				return ids
+			}
+			query.Set("cursor", *page.NextCursor)
+		}
+	}
+
+	all := list(url.Values{"limit": {"100"}})
+	require.Len(t, all, 5)
+
+	assert.Equal(t, all, list(url.Values{"limit": {"2"}}), "pages add up")
+	desc := list(url.Values{"limit": {"2"}, "sort": {"desc"}})
+	for i := range all {
+		assert.Equal(t, all[i], desc[len(desc)-1-i], "descending is the reverse")
+	}
+	window := list(url.Values{"since": {start.Add(time.Minute).Format(time.RFC3339)}, "until": {start.Add(3 * time.Minute).Format(time.RFC3339)}})
+	assert.Equal(t, all[1:4], window)
+
+	for _, query := range []string{"limit=0", fmt.Sprintf("limit=%d", maxPageLimit+1), "cursor=bogus", "sort=sideways", "since=yesterday", "author_id=me"} {
+		resp := doJSON(t, "GET", api.URL+"/api/zingers?"+query, "", nil, nil)
+		assert.Equal(t, 400, resp.StatusCode, query)
+	}
+}